@@ -0,0 +1,145 @@
+package classIndex
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/token"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+type Param struct {
+	DecType string
+	Name    string
+}
+
+type Subroutine struct {
+	Class   string
+	Name    string
+	Kind    token.TokenType
+	DecType string
+	Params  []Param
+	token.Span
+}
+
+func (s *Subroutine) FullName() string { return s.Class + "." + s.Name }
+
+type Class struct {
+	Name        string
+	Subroutines map[string]*Subroutine
+	Builtin     bool
+	token.Span
+}
+
+type Index struct {
+	classes map[string]*Class
+}
+
+func New() *Index {
+	idx := &Index{classes: make(map[string]*Class)}
+	for _, src := range builtins {
+		c, diags := parser.New(tokenizer.NewFile("Jack OS", src)).ParseClass()
+		if len(diags) != 0 {
+			log.Fatalf("Invalid builtin class declaration, received: %v", diags)
+		}
+		idx.Add(c)
+		idx.classes[c.Ident.Value].Builtin = true
+	}
+	return idx
+}
+
+func (idx *Index) Add(c *parseTree.Class) []diagnostic.Diagnostic {
+	if prev, ok := idx.classes[c.Ident.Value]; ok && !prev.Builtin {
+		return []diagnostic.Diagnostic{{
+			Severity: diagnostic.ERROR,
+			Message:  fmt.Sprintf("Duplicate class '%s', first declared at %s", c.Ident.Value, prev.Start),
+			Span:     c.Ident.Span,
+		}}
+	}
+
+	class := &Class{
+		Name:        c.Ident.Value,
+		Subroutines: make(map[string]*Subroutine),
+		Span:        c.Ident.Span,
+	}
+	var diags []diagnostic.Diagnostic
+	for _, sd := range c.Subroutines() {
+		if prev, ok := class.Subroutines[sd.Ident.Value]; ok {
+			diags = append(diags, diagnostic.Diagnostic{
+				Severity: diagnostic.ERROR,
+				Message:  fmt.Sprintf("Duplicate subroutine '%s', first declared at %s", prev.FullName(), prev.Start),
+				Span:     sd.Ident.Span,
+			})
+			continue
+		}
+		sub := &Subroutine{
+			Class:   class.Name,
+			Name:    sd.Ident.Value,
+			Kind:    sd.Kind.Type,
+			DecType: sd.DecType.Literal,
+			Span:    sd.Ident.Span,
+		}
+		for _, param := range sd.Params {
+			sub.Params = append(sub.Params, Param{DecType: param.DecType.Literal, Name: param.Ident.Value})
+		}
+		class.Subroutines[sub.Name] = sub
+	}
+	idx.classes[class.Name] = class
+	return diags
+}
+
+func (idx *Index) Class(name string) (*Class, bool) {
+	class, ok := idx.classes[name]
+	return class, ok
+}
+
+func (idx *Index) Suggest(className string, name string) string {
+	class, ok := idx.classes[className]
+	if !ok {
+		return ""
+	}
+
+	best, bestDistance := "", 3
+	for candidate := range class.Subroutines {
+		if strings.EqualFold(candidate, name) {
+			return candidate
+		}
+		if d := distance(candidate, name); d < bestDistance || d == bestDistance && candidate < best {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func (idx *Index) Subroutine(className string, name string) (*Subroutine, bool) {
+	class, ok := idx.classes[className]
+	if !ok {
+		return nil, false
+	}
+	sub, ok := class.Subroutines[name]
+	return sub, ok
+}
+
+func distance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,63 @@
+package classIndex
+
+import (
+	"testing"
+
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		inputs   []string
+		expected []string
+	}{
+		{
+			[]string{`class Main {
+				function void f() { return; }
+				method int g() { return 0; }
+			}`},
+			[]string{},
+		},
+		{
+			[]string{`class Main {
+				function void f() { return; }
+				method int f() { return 0; }
+			}`},
+			[]string{"3:16: error: Duplicate subroutine 'Main.f', first declared at 2:19"},
+		},
+		{
+			[]string{`class Main {}`, `class Main {}`},
+			[]string{"1:7: error: Duplicate class 'Main', first declared at 1:7"},
+		},
+		{
+			[]string{`class Math {
+				function int abs(int x) { return x; }
+			}`},
+			[]string{},
+		},
+	}
+
+	for i, test := range tests {
+		idx := New()
+		var received []string
+		for _, input := range test.inputs {
+			class, diags := parser.New(tokenizer.New(input)).ParseClass()
+			if len(diags) != 0 {
+				t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+			}
+			for _, d := range idx.Add(class) {
+				received = append(received, d.String())
+			}
+		}
+
+		if len(received) != len(test.expected) {
+			t.Fatalf("Add() test index %d, expected %d diagnostics, received: %v", i, len(test.expected), received)
+		}
+		for j, d := range received {
+			if d != test.expected[j] {
+				t.Fatalf("Add() test index %d, expected: %s, received: %s", i, test.expected[j], d)
+			}
+		}
+	}
+}
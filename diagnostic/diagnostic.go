@@ -0,0 +1,52 @@
+package diagnostic
+
+import (
+	"bytes"
+
+	"github.com/tivt2/jack-compiler/token"
+)
+
+type Severity string
+
+const (
+	ERROR   = "error"
+	WARNING = "warning"
+	INFO    = "info"
+)
+
+type Diagnostic struct {
+	Severity   Severity
+	Message    string
+	Expected   string
+	Got        string
+	Suggestion string
+	token.Span
+}
+
+func (d Diagnostic) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(d.Start.String() + ": ")
+	out.WriteString(string(d.Severity) + ": ")
+	out.WriteString(d.Message)
+	if d.Expected != "" {
+		out.WriteString(", expected " + d.Expected)
+		if d.Got != "" {
+			out.WriteString(", got " + d.Got)
+		}
+	}
+	if d.Suggestion != "" {
+		out.WriteString(" (hint: " + d.Suggestion + ")")
+	}
+
+	return out.String()
+}
+
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == ERROR {
+			return true
+		}
+	}
+	return false
+}
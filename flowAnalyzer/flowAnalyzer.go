@@ -0,0 +1,239 @@
+package flowAnalyzer
+
+import (
+	"fmt"
+
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/token"
+)
+
+type Analyzer struct {
+	c       *parseTree.Class
+	current *parseTree.SubroutineDec
+
+	locals   map[string]bool
+	reported map[string]bool
+
+	diagnostics []diagnostic.Diagnostic
+}
+
+func New(c *parseTree.Class) *Analyzer {
+	return &Analyzer{c: c}
+}
+
+func Analyze(c *parseTree.Class) []diagnostic.Diagnostic {
+	return New(c).Analyze()
+}
+
+func (a *Analyzer) Analyze() []diagnostic.Diagnostic {
+	for _, sd := range a.c.Subroutines() {
+		a.analyzeSubroutineDec(sd)
+	}
+	return a.diagnostics
+}
+
+func (a *Analyzer) analyzeSubroutineDec(sd *parseTree.SubroutineDec) {
+	a.current = sd
+	a.checkAssignments(sd)
+	if a.analyzeStatements(sd.SubroutineBody.Statements) {
+		return
+	}
+
+	suggestion := fmt.Sprintf("return a value of type %s on every path", sd.DecType.Literal)
+	switch {
+	case sd.Kind.Type == token.CONSTRUCTOR:
+		suggestion = "end the constructor with 'return this;'"
+	case sd.DecType.Type == token.VOID:
+		suggestion = "end the subroutine with 'return;'"
+	}
+	a.report(diagnostic.ERROR, sd.Ident.Span, fmt.Sprintf("Missing return statement in %s", a.name()), "", "", suggestion)
+}
+
+// analyzeStatements reports whether control can never fall through stmts
+func (a *Analyzer) analyzeStatements(stmts []parseTree.Statement) bool {
+	terminated := false
+	for _, stmt := range stmts {
+		if terminated {
+			a.report(diagnostic.WARNING, stmt.Range(), "Unreachable statement", "", "", "")
+			return true
+		}
+		terminated = a.analyzeStatement(stmt)
+	}
+	return terminated
+}
+
+func (a *Analyzer) analyzeStatement(stmt parseTree.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *parseTree.ReturnStatement:
+		a.analyzeReturn(stmt)
+		return true
+	case *parseTree.IfStatement:
+		ifTerminates := a.analyzeStatements(stmt.IfStmts)
+		elseTerminates := a.analyzeStatements(stmt.Else)
+		return ifTerminates && elseTerminates && len(stmt.Else) > 0
+	case *parseTree.WhileStatement:
+		a.analyzeStatements(stmt.Stmts)
+		return isTrue(stmt.Expression)
+	}
+	return false
+}
+
+func (a *Analyzer) analyzeReturn(stmt *parseTree.ReturnStatement) {
+	sd := a.current
+	switch {
+	case sd.Kind.Type == token.CONSTRUCTOR:
+		if stmt.Expression == nil || !isThis(stmt.Expression) {
+			got := "no value"
+			if stmt.Expression != nil {
+				got = stmt.Expression.String()
+			}
+			a.report(diagnostic.ERROR, stmt.Span, fmt.Sprintf("Constructor %s must return this", a.name()), "'this'", got, "")
+		}
+	case sd.DecType.Type == token.VOID:
+		if stmt.Expression != nil {
+			a.report(diagnostic.ERROR, stmt.Expression.Range(), fmt.Sprintf("Void subroutine %s returns a value", a.name()), "", "", "use 'return;'")
+		}
+	default:
+		if stmt.Expression == nil {
+			a.report(diagnostic.ERROR, stmt.Span, fmt.Sprintf("Missing return value in %s", a.name()), sd.DecType.Literal, "no value", "")
+		}
+	}
+}
+
+func (a *Analyzer) checkAssignments(sd *parseTree.SubroutineDec) {
+	a.locals = make(map[string]bool)
+	a.reported = make(map[string]bool)
+	for _, varDec := range sd.SubroutineBody.Vars() {
+		a.locals[varDec.Ident.Value] = true
+	}
+	a.assignStatements(sd.SubroutineBody.Statements, make(map[string]bool))
+}
+
+// assignStatements returns the locals definitely assigned after stmts and whether control can never fall through them
+func (a *Analyzer) assignStatements(stmts []parseTree.Statement, assigned map[string]bool) (map[string]bool, bool) {
+	for _, stmt := range stmts {
+		var terminated bool
+		assigned, terminated = a.assignStatement(stmt, assigned)
+		if terminated {
+			return assigned, true
+		}
+	}
+	return assigned, false
+}
+
+func (a *Analyzer) assignStatement(stmt parseTree.Statement, assigned map[string]bool) (map[string]bool, bool) {
+	switch stmt := stmt.(type) {
+	case *parseTree.LetStatement:
+		if stmt.Ident.Indexer != nil {
+			a.checkReads(stmt.Ident, assigned)
+		}
+		a.checkReads(stmt.Expression, assigned)
+		if stmt.Ident.Indexer == nil && a.locals[stmt.Ident.Value] {
+			assigned = copyOf(assigned)
+			assigned[stmt.Ident.Value] = true
+		}
+	case *parseTree.ReturnStatement:
+		if stmt.Expression != nil {
+			a.checkReads(stmt.Expression, assigned)
+		}
+		return assigned, true
+	case *parseTree.DoStatement:
+		a.checkReads(stmt.Expression, assigned)
+	case *parseTree.WhileStatement:
+		a.checkReads(stmt.Expression, assigned)
+		a.assignStatements(stmt.Stmts, assigned)
+		return assigned, isTrue(stmt.Expression)
+	case *parseTree.IfStatement:
+		a.checkReads(stmt.Expression, assigned)
+		ifAssigned, ifTerminated := a.assignStatements(stmt.IfStmts, assigned)
+		elseAssigned, elseTerminated := a.assignStatements(stmt.Else, assigned)
+		switch {
+		case ifTerminated && elseTerminated:
+			return assigned, true
+		case ifTerminated:
+			return elseAssigned, false
+		case elseTerminated:
+			return ifAssigned, false
+		}
+		out := make(map[string]bool)
+		for name := range ifAssigned {
+			if elseAssigned[name] {
+				out[name] = true
+			}
+		}
+		return out, false
+	}
+	return assigned, false
+}
+
+func (a *Analyzer) checkReads(exp parseTree.Expression, assigned map[string]bool) {
+	switch exp := exp.(type) {
+	case *parseTree.Prefix:
+		a.checkReads(exp.Expression, assigned)
+	case *parseTree.Group:
+		a.checkReads(exp.Expression, assigned)
+	case *parseTree.Infix:
+		a.checkReads(exp.Left, assigned)
+		a.checkReads(exp.Right, assigned)
+	case *parseTree.Identifier:
+		a.checkRead(exp, assigned)
+		if exp.Indexer != nil {
+			a.checkReads(exp.Indexer, assigned)
+		}
+	case *parseTree.SubroutineCall:
+		if exp.Ident != nil {
+			a.checkRead(exp.Ident, assigned)
+		}
+		for _, e := range exp.ExpList {
+			a.checkReads(e, assigned)
+		}
+	}
+}
+
+func (a *Analyzer) checkRead(ident *parseTree.Identifier, assigned map[string]bool) {
+	if !a.locals[ident.Value] || assigned[ident.Value] || a.reported[ident.Value] {
+		return
+	}
+	a.reported[ident.Value] = true
+	a.report(diagnostic.WARNING, ident.Span, fmt.Sprintf("Local variable '%s' may be used before it is assigned", ident.Value), "", "", "assign it with 'let' before reading it, locals start as 0")
+}
+
+func copyOf(assigned map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(assigned)+1)
+	for name := range assigned {
+		out[name] = true
+	}
+	return out
+}
+
+func (a *Analyzer) name() string {
+	return a.c.Ident.Value + "." + a.current.Ident.Value
+}
+
+func (a *Analyzer) report(severity diagnostic.Severity, span token.Span, msg string, expected string, got string, suggestion string) {
+	a.diagnostics = append(a.diagnostics, diagnostic.Diagnostic{
+		Severity:   severity,
+		Message:    msg,
+		Expected:   expected,
+		Got:        got,
+		Suggestion: suggestion,
+		Span:       span,
+	})
+}
+
+func isThis(exp parseTree.Expression) bool {
+	if g, ok := exp.(*parseTree.Group); ok {
+		return isThis(g.Expression)
+	}
+	kc, ok := exp.(*parseTree.KeywordConstant)
+	return ok && kc.Token.Type == token.THIS
+}
+
+func isTrue(exp parseTree.Expression) bool {
+	if g, ok := exp.(*parseTree.Group); ok {
+		return isTrue(g.Expression)
+	}
+	kc, ok := exp.(*parseTree.KeywordConstant)
+	return ok && kc.Token.Type == token.TRUE
+}
@@ -0,0 +1,133 @@
+package flowAnalyzer
+
+import (
+	"testing"
+
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			`class Main {
+				constructor Main new() { return this; }
+				function int sign(int x) {
+					if (x < 0) { return -1; } else { return 1; }
+				}
+				function void loop() {
+					while (true) { do Sys.wait(1); }
+				}
+				function void main() { return; }
+			}`,
+			[]string{},
+		},
+		{
+			`class Main {
+				function int sign(int x) {
+					if (x < 0) { return -1; }
+				}
+				function void main() {
+					do Main.sign(1);
+				}
+			}`,
+			[]string{
+				"2:18: error: Missing return statement in Main.sign (hint: return a value of type int on every path)",
+				"5:19: error: Missing return statement in Main.main (hint: end the subroutine with 'return;')",
+			},
+		},
+		{
+			`class Main {
+				constructor Main new() { return 1; }
+				constructor Main other() { return; }
+				function void main() { return 1; }
+				function int get() { return; }
+			}`,
+			[]string{
+				"2:30: error: Constructor Main.new must return this, expected 'this', got 1",
+				"3:32: error: Constructor Main.other must return this, expected 'this', got no value",
+				"4:35: error: Void subroutine Main.main returns a value (hint: use 'return;')",
+				"5:26: error: Missing return value in Main.get, expected int, got no value",
+			},
+		},
+		{
+			`class Main {
+				function void main() {
+					return;
+					do Main.main();
+					do Main.main();
+				}
+				function int f() {
+					if (true) { return 1; let x = 2; } else { return 2; }
+				}
+			}`,
+			[]string{
+				"4:6: warning: Unreachable statement",
+				"8:28: warning: Unreachable statement",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		tkzr := tokenizer.New(test.input)
+		p := parser.New(tkzr)
+		class, diags := p.ParseClass()
+		if len(diags) != 0 {
+			t.Fatalf("ParseClass() test index %d, unexpected diagnostics: %v", i, diags)
+		}
+
+		diags = Analyze(class)
+
+		if len(diags) != len(test.expected) {
+			t.Fatalf("Analyze() test index %d, expected %d diagnostics, received: %v", i, len(test.expected), diags)
+		}
+		for j, d := range diags {
+			if d.String() != test.expected[j] {
+				t.Fatalf("Analyze() test index %d, expected: %s, received: %s", i, test.expected[j], d.String())
+			}
+		}
+	}
+}
+
+func TestAnalyzeAssignments(t *testing.T) {
+	input := `class Main {
+		function int main(int n) {
+			var int a, b, c, d, e, f;
+			var Array arr;
+			let a = n;
+			if (a > 0) { let b = 1; let c = 1; } else { let b = 2; }
+			while (a < 10) { let d = 1; let a = a + e; }
+			if (n = 0) { return b; } else { let f = 1; }
+			let arr[a] = c + d + f;
+			return b + d;
+		}
+	}`
+
+	expected := []string{
+		"7:44: warning: Local variable 'e' may be used before it is assigned (hint: assign it with 'let' before reading it, locals start as 0)",
+		"9:8: warning: Local variable 'arr' may be used before it is assigned (hint: assign it with 'let' before reading it, locals start as 0)",
+		"9:17: warning: Local variable 'c' may be used before it is assigned (hint: assign it with 'let' before reading it, locals start as 0)",
+		"9:21: warning: Local variable 'd' may be used before it is assigned (hint: assign it with 'let' before reading it, locals start as 0)",
+	}
+
+	tkzr := tokenizer.New(input)
+	p := parser.New(tkzr)
+	class, diags := p.ParseClass()
+	if len(diags) != 0 {
+		t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+	}
+
+	diags = Analyze(class)
+
+	if len(diags) != len(expected) {
+		t.Fatalf("Analyze() expected %d diagnostics, received: %v", len(expected), diags)
+	}
+	for i, d := range diags {
+		if d.String() != expected[i] {
+			t.Fatalf("Analyze() expected: %s, received: %s", expected[i], d.String())
+		}
+	}
+}
@@ -2,64 +2,158 @@ package jackCompiler
 
 import (
 	"fmt"
+	"math/bits"
+	"path/filepath"
+	"strings"
 
+	"github.com/tivt2/jack-compiler/classIndex"
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/flowAnalyzer"
+	"github.com/tivt2/jack-compiler/lint"
+	"github.com/tivt2/jack-compiler/optimizer"
 	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/peephole"
+	"github.com/tivt2/jack-compiler/semanticAnalyzer"
 	"github.com/tivt2/jack-compiler/symbolTable"
-	"github.com/tivt2/jack-compiler/syntaxAnalyzer"
 	"github.com/tivt2/jack-compiler/token"
+	"github.com/tivt2/jack-compiler/typeChecker"
+	"github.com/tivt2/jack-compiler/usageAnalyzer"
 	"github.com/tivt2/jack-compiler/vmWriter"
 )
 
 type JackCompiler struct {
-	w *vmWriter.VMWriter
-	s *symbolTable.SymbolTable
-	c *parseTree.Class
+	w   *vmWriter.VMWriter
+	s   *symbolTable.SymbolTable
+	c   *parseTree.Class
+	idx *classIndex.Index
 
 	ifCounter    int
 	whileCounter int
+	condCounter  int
+
+	reference    bool
+	shortCircuit bool
+	optimize     int
+	peephole     peephole.Config
+	stats        peephole.Stats
 }
 
-func New(filePath string) *JackCompiler {
-	w := vmWriter.New(filePath)
-	s := symbolTable.New()
-	c := syntaxAnalyzer.ParseTree(filePath)
+type Options struct {
+	Index        *classIndex.Index
+	TypeCheck    typeChecker.Config
+	Lint         *lint.Linter
+	NameByClass  bool // write the .vm file after the class instead of the source file
+	Optimize     int  // 0 disables optimizations, 1 folds constants, 2 also runs the peephole pass
+	Peephole     peephole.Config
+	ShortCircuit bool // compile & and | in if and while conditions to jumps
+	Reference    bool // match the labels and code of the official JackCompiler, disables the other code options
+}
 
-	for _, dec := range c.ClassVarDecs {
-		s.Define(dec.Ident.Value, dec.DecType.Literal, dec.Kind.Literal)
+func New(filePath string, c *parseTree.Class, opts Options) (*JackCompiler, []diagnostic.Diagnostic) {
+	var diags []diagnostic.Diagnostic
+	if opts.Index == nil {
+		opts.Index = classIndex.New()
+		diags = opts.Index.Add(c)
 	}
 
-	return &JackCompiler{
-		w: w,
-		s: s,
-		c: c,
+	fileName := filepath.Base(filePath)
+	if name := strings.TrimSuffix(fileName, ".jack"); name != c.Ident.Value {
+		if opts.NameByClass {
+			filePath = filepath.Join(filepath.Dir(filePath), c.Ident.Value+".jack")
+		} else {
+			diags = append(diags, diagnostic.Diagnostic{
+				Severity:   diagnostic.ERROR,
+				Message:    fmt.Sprintf("Class name does not match file name %s", fileName),
+				Expected:   fmt.Sprintf("class %s", name),
+				Got:        fmt.Sprintf("class %s", c.Ident.Value),
+				Suggestion: fmt.Sprintf("rename the class or the file, or use -name-by-class to write %s.vm", c.Ident.Value),
+				Span:       c.Ident.Span,
+			})
+		}
+	}
+
+	diags = append(diags, semanticAnalyzer.Analyze(c, opts.Index)...)
+	if diagnostic.HasErrors(diags) {
+		return nil, diags
+	}
+
+	diags = append(diags, flowAnalyzer.Analyze(c)...)
+	if diagnostic.HasErrors(diags) {
+		return nil, diags
+	}
+
+	diags = append(diags, typeChecker.Check(c, opts.Index, opts.TypeCheck)...)
+	diags = append(diags, usageAnalyzer.Analyze(c)...)
+	if opts.Lint != nil {
+		diags = append(diags, opts.Lint.Lint(c)...)
+	}
+	if diagnostic.HasErrors(diags) {
+		return nil, diags
+	}
+
+	if opts.Reference {
+		opts.Optimize = 0
+		opts.ShortCircuit = false
 	}
+	if opts.Optimize >= 1 {
+		optimizer.Optimize(c)
+	}
+	if opts.Peephole.Void == nil {
+		idx := opts.Index
+		opts.Peephole.Void = func(name string) bool {
+			className, subName, _ := strings.Cut(name, ".")
+			sub, ok := idx.Subroutine(className, subName)
+			return ok && sub.DecType == token.VOID
+		}
+	}
+
+	w := vmWriter.New(filePath)
+	s := symbolTable.New()
+
+	return &JackCompiler{
+		w:            w,
+		s:            s,
+		c:            c,
+		idx:          opts.Index,
+		reference:    opts.Reference,
+		shortCircuit: opts.ShortCircuit,
+		optimize:     opts.Optimize,
+		peephole:     opts.Peephole,
+	}, diags
 }
 
 func (jc *JackCompiler) Compile() {
-	jc.w.WriteComment(fmt.Sprintf("class %s", jc.c.Ident.Value))
-
-	for _, dec := range jc.c.ClassVarDecs {
-		jc.s.Define(dec.Ident.Value, dec.DecType.Literal, dec.Kind.Literal)
+	if !jc.reference {
+		jc.w.WriteComment(fmt.Sprintf("class %s", jc.c.Ident.Value))
 	}
 
-	for _, subDec := range jc.c.SubroutineDecs {
+	jc.s.DefineClass(jc.c)
+
+	for _, subDec := range jc.c.Subroutines() {
 		jc.CompileSubroutineDec(subDec)
 	}
 
+	code := jc.w.Out.String()
+	jc.stats = peephole.Stats{Before: peephole.Count(code), After: peephole.Count(code)}
+	if jc.optimize >= 2 {
+		code, jc.stats = peephole.Optimize(code, jc.peephole)
+		jc.w.Out.Reset()
+		jc.w.Out.WriteString(code)
+	}
+
 	jc.w.Close()
 }
 
+func (jc *JackCompiler) Stats() peephole.Stats {
+	return jc.stats
+}
+
 func (jc *JackCompiler) CompileSubroutineDec(sd *parseTree.SubroutineDec) {
-	jc.s.Reset()
-	if sd.Kind.Type == token.METHOD {
-		jc.s.Define("this", jc.c.Ident.Value, "argument")
-	}
-	for _, param := range sd.Params {
-		jc.s.Define(param.Ident.Token.Literal, param.DecType.Literal, "argument")
-	}
-	for _, varDec := range sd.SubroutineBody.VarDecs {
-		jc.s.Define(varDec.Ident.Token.Literal, varDec.DecType.Literal, "local")
+	if jc.reference {
+		jc.ifCounter = 0
+		jc.whileCounter = 0
 	}
+	jc.s.DefineSubroutine(jc.c, sd)
 
 	jc.w.WriteFunction(fmt.Sprintf("%s.%s", jc.c.Ident.Value, sd.Ident.Value), jc.s.VarCount("local"))
 	switch sd.Kind.Type {
@@ -88,9 +182,7 @@ func (jc *JackCompiler) CompileStatement(stmt parseTree.Statement) {
 			jc.CompileExpression(stmt.Expression)
 			jc.w.WritePop(jc.s.KindOf(stmt.Ident.Value), jc.s.IndexOf(stmt.Ident.Value))
 		} else {
-			jc.w.WritePush(jc.s.KindOf(stmt.Ident.Value), jc.s.IndexOf(stmt.Ident.Value))
-			jc.CompileExpression(stmt.Ident.Indexer)
-			jc.w.WriteArithmetic(token.PLUS)
+			jc.compileArrayAddress(stmt.Ident)
 			jc.CompileExpression(stmt.Expression)
 			jc.w.WritePop("temp", 0)
 			jc.w.WritePop("pointer", 1)
@@ -110,20 +202,24 @@ func (jc *JackCompiler) CompileStatement(stmt parseTree.Statement) {
 	case *parseTree.WhileStatement:
 		counter := jc.whileCounter
 		jc.whileCounter++
-		jc.w.WriteLabel(fmt.Sprintf("WHILE%d", counter))
-		jc.CompileExpression(stmt.Expression)
-		jc.w.WriteArithmetic(token.NOT)
-		jc.w.WriteIf(fmt.Sprintf("BREAK%d", counter))
+		whileLabel, breakLabel := fmt.Sprintf("WHILE%d", counter), fmt.Sprintf("BREAK%d", counter)
+		if jc.reference {
+			whileLabel, breakLabel = fmt.Sprintf("WHILE_EXP%d", counter), fmt.Sprintf("WHILE_END%d", counter)
+		}
+		jc.w.WriteLabel(whileLabel)
+		jc.CompileCondition(stmt.Expression, breakLabel)
 		jc.CompileStatements(stmt.Stmts)
-		jc.w.WriteGoto(fmt.Sprintf("WHILE%d", counter))
-		jc.w.WriteLabel(fmt.Sprintf("BREAK%d", counter))
+		jc.w.WriteGoto(whileLabel)
+		jc.w.WriteLabel(breakLabel)
 	case *parseTree.IfStatement:
+		if jc.reference {
+			jc.compileReferenceIf(stmt)
+			return
+		}
 		elseLen := len(stmt.Else)
 		counter := jc.ifCounter
 		jc.ifCounter++
-		jc.CompileExpression(stmt.Expression)
-		jc.w.WriteArithmetic(token.NOT)
-		jc.w.WriteIf(fmt.Sprintf("ELSE%d", counter))
+		jc.CompileCondition(stmt.Expression, fmt.Sprintf("ELSE%d", counter))
 		jc.CompileStatements(stmt.IfStmts)
 		if elseLen > 0 {
 			jc.w.WriteGoto(fmt.Sprintf("IF%d", counter))
@@ -136,6 +232,99 @@ func (jc *JackCompiler) CompileStatement(stmt parseTree.Statement) {
 	}
 }
 
+// compileReferenceIf jumps on the condition itself instead of its negation, like the official compiler
+func (jc *JackCompiler) compileReferenceIf(stmt *parseTree.IfStatement) {
+	counter := jc.ifCounter
+	jc.ifCounter++
+	jc.CompileExpression(stmt.Expression)
+	jc.w.WriteIf(fmt.Sprintf("IF_TRUE%d", counter))
+	jc.w.WriteGoto(fmt.Sprintf("IF_FALSE%d", counter))
+	jc.w.WriteLabel(fmt.Sprintf("IF_TRUE%d", counter))
+	jc.CompileStatements(stmt.IfStmts)
+	if len(stmt.Else) > 0 {
+		jc.w.WriteGoto(fmt.Sprintf("IF_END%d", counter))
+	}
+	jc.w.WriteLabel(fmt.Sprintf("IF_FALSE%d", counter))
+	if len(stmt.Else) > 0 {
+		jc.CompileStatements(stmt.Else)
+		jc.w.WriteLabel(fmt.Sprintf("IF_END%d", counter))
+	}
+}
+
+// CompileCondition jumps to falseLabel when exp is false and falls through otherwise
+func (jc *JackCompiler) CompileCondition(exp parseTree.Expression, falseLabel string) {
+	if jc.shortCircuit {
+		jc.compileBranch(exp, falseLabel, false)
+		return
+	}
+	jc.CompileExpression(exp)
+	jc.w.WriteArithmetic(token.NOT)
+	jc.w.WriteIf(falseLabel)
+}
+
+// compileBranch jumps to label when exp evaluates to when, skipping the right operand of & and | once the result is known
+func (jc *JackCompiler) compileBranch(exp parseTree.Expression, label string, when bool) {
+	if g, ok := exp.(*parseTree.Group); ok {
+		jc.compileBranch(g.Expression, label, when)
+		return
+	}
+
+	infix, ok := exp.(*parseTree.Infix)
+	ok = ok && jc.boolean(infix.Left) && jc.boolean(infix.Right)
+	switch {
+	case ok && (infix.Operator.Type == token.AMP && !when || infix.Operator.Type == token.BAR && when):
+		jc.compileBranch(infix.Left, label, when)
+		jc.compileBranch(infix.Right, label, when)
+	case ok && (infix.Operator.Type == token.AMP || infix.Operator.Type == token.BAR):
+		skip := fmt.Sprintf("COND%d", jc.condCounter)
+		jc.condCounter++
+		jc.compileBranch(infix.Left, skip, !when)
+		jc.compileBranch(infix.Right, label, when)
+		jc.w.WriteLabel(skip)
+	default:
+		jc.CompileExpression(exp)
+		if !when {
+			jc.w.WriteArithmetic(token.NOT)
+		}
+		jc.w.WriteIf(label)
+	}
+}
+
+// boolean reports whether exp is known to be 0 or -1, only then & and | can be split into jumps without changing bitwise results
+func (jc *JackCompiler) boolean(exp parseTree.Expression) bool {
+	switch exp := exp.(type) {
+	case *parseTree.Group:
+		return jc.boolean(exp.Expression)
+	case *parseTree.Prefix:
+		return exp.Operator.Type == token.NOT && jc.boolean(exp.Expression)
+	case *parseTree.Infix:
+		switch exp.Operator.Type {
+		case token.LT, token.GT, token.ASSIGN:
+			return true
+		case token.AMP, token.BAR:
+			return jc.boolean(exp.Left) && jc.boolean(exp.Right)
+		}
+	case *parseTree.KeywordConstant:
+		return exp.Token.Type == token.TRUE || exp.Token.Type == token.FALSE
+	case *parseTree.Identifier:
+		return exp.Indexer == nil && jc.s.TypeOf(exp.Value) == "boolean"
+	case *parseTree.SubroutineCall:
+		if jc.idx == nil {
+			return false
+		}
+		className := jc.c.Ident.Value
+		if exp.Ident != nil {
+			className = exp.Ident.Value
+			if decType := jc.s.TypeOf(exp.Ident.Value); decType != "" {
+				className = decType
+			}
+		}
+		sub, ok := jc.idx.Subroutine(className, exp.Subroutine.Value)
+		return ok && sub.DecType == "boolean"
+	}
+	return false
+}
+
 func (jc *JackCompiler) CompileExpression(exp parseTree.Expression) {
 	switch exp := exp.(type) {
 	case *parseTree.Prefix:
@@ -145,7 +334,12 @@ func (jc *JackCompiler) CompileExpression(exp parseTree.Expression) {
 		} else {
 			jc.w.WriteArithmetic(exp.Operator.Literal)
 		}
+	case *parseTree.Group:
+		jc.CompileExpression(exp.Expression)
 	case *parseTree.Infix:
+		if jc.optimize >= 1 && jc.compileStrengthReduced(exp) {
+			return
+		}
 		jc.CompileExpression(exp.Left)
 		jc.CompileExpression(exp.Right)
 		switch exp.Operator.Type {
@@ -157,10 +351,10 @@ func (jc *JackCompiler) CompileExpression(exp parseTree.Expression) {
 			jc.w.WriteArithmetic(exp.Operator.Literal)
 		}
 	case *parseTree.Identifier:
-		jc.w.WritePush(jc.s.KindOf(exp.Value), jc.s.IndexOf(exp.Value))
-		if exp.Indexer != nil {
-			jc.CompileExpression(exp.Indexer)
-			jc.w.WriteArithmetic(token.PLUS)
+		if exp.Indexer == nil {
+			jc.w.WritePush(jc.s.KindOf(exp.Value), jc.s.IndexOf(exp.Value))
+		} else {
+			jc.compileArrayAddress(exp)
 			jc.w.WritePop("pointer", 1)
 			jc.w.WritePush("that", 0)
 		}
@@ -176,8 +370,13 @@ func (jc *JackCompiler) CompileExpression(exp parseTree.Expression) {
 	case *parseTree.KeywordConstant:
 		switch exp.Token.Type {
 		case token.TRUE:
-			jc.w.WritePush("constant", 1)
-			jc.w.WriteArithmetic("neg")
+			if jc.reference {
+				jc.w.WritePush("constant", 0)
+				jc.w.WriteArithmetic(token.NOT)
+			} else {
+				jc.w.WritePush("constant", 1)
+				jc.w.WriteArithmetic("neg")
+			}
 		case token.FALSE:
 			jc.w.WritePush("constant", 0)
 		case token.NULL:
@@ -208,3 +407,174 @@ func (jc *JackCompiler) CompileExpression(exp parseTree.Expression) {
 		}
 	}
 }
+
+// compileArrayAddress pushes the address of ident[indexer], the official compiler pushes the index first
+func (jc *JackCompiler) compileArrayAddress(ident *parseTree.Identifier) {
+	if jc.reference {
+		jc.CompileExpression(ident.Indexer)
+		jc.w.WritePush(jc.s.KindOf(ident.Value), jc.s.IndexOf(ident.Value))
+	} else {
+		jc.w.WritePush(jc.s.KindOf(ident.Value), jc.s.IndexOf(ident.Value))
+		jc.CompileExpression(ident.Indexer)
+	}
+	jc.w.WriteArithmetic(token.PLUS)
+}
+
+const maxStrengthReduction = 32
+
+// compileStrengthReduced lowers multiplication and division by a constant without calling Math
+func (jc *JackCompiler) compileStrengthReduced(exp *parseTree.Infix) bool {
+	switch exp.Operator.Type {
+	case token.ASTERISK:
+		if c, ok := optimizer.Value(exp.Right); ok {
+			return jc.compileMultiply(exp.Left, c)
+		}
+		if c, ok := optimizer.Value(exp.Left); ok {
+			return jc.compileMultiply(exp.Right, c)
+		}
+	case token.FSLASH:
+		if c, ok := optimizer.Value(exp.Right); ok && (c == 1 || c == -1) {
+			jc.CompileExpression(exp.Left)
+			if c == -1 {
+				jc.w.WriteArithmetic("neg")
+			}
+			return true
+		}
+		if c, ok := optimizer.Value(exp.Right); ok {
+			return jc.compileDivide(exp.Left, c)
+		}
+	}
+	return false
+}
+
+// compileDivide emits x / c for c = ±2^k by adding up the bits of x from k, temp 1 holds x biased by 2^k - 1 when negative to round toward zero
+func (jc *JackCompiler) compileDivide(x parseTree.Expression, c int) bool {
+	negative := c < 0
+	if negative {
+		c = -c
+	}
+	if c < 2 || c > 16384 || bits.OnesCount(uint(c)) != 1 {
+		return false
+	}
+	k := bits.Len(uint(c)) - 1
+
+	simple := isSimple(x)
+	jc.CompileExpression(x)
+	if simple {
+		jc.CompileExpression(x)
+	} else {
+		jc.w.WritePop("temp", 1)
+		jc.w.WritePush("temp", 1)
+		jc.w.WritePush("temp", 1)
+	}
+	jc.w.WritePush("constant", 0)
+	jc.w.WriteArithmetic(token.LT)
+	jc.w.WritePush("constant", c-1)
+	jc.w.WriteArithmetic(token.AMP)
+	jc.w.WriteArithmetic(token.PLUS)
+	jc.w.WritePop("temp", 1)
+
+	for i := k; i < 15; i++ {
+		jc.w.WritePush("temp", 1)
+		jc.w.WritePush("constant", 1<<i)
+		jc.w.WriteArithmetic(token.AMP)
+		jc.w.WritePush("constant", 0)
+		jc.w.WriteArithmetic(token.GT)
+		jc.w.WritePush("constant", 1<<(i-k))
+		jc.w.WriteArithmetic(token.AMP)
+		if i > k {
+			jc.w.WriteArithmetic(token.PLUS)
+		}
+	}
+	jc.w.WritePush("temp", 1)
+	jc.w.WritePush("constant", 0)
+	jc.w.WriteArithmetic(token.LT)
+	jc.w.WritePush("constant", 1<<(15-k))
+	jc.w.WriteArithmetic("neg")
+	jc.w.WriteArithmetic(token.AMP)
+	jc.w.WriteArithmetic(token.PLUS)
+	if negative {
+		jc.w.WriteArithmetic("neg")
+	}
+	return true
+}
+
+// compileMultiply emits x * c by doubling and adding, temp 1 holds x and temp 2 duplicates the running result
+func (jc *JackCompiler) compileMultiply(x parseTree.Expression, c int) bool {
+	negative := c < 0
+	if negative {
+		c = -c
+	}
+	if c == 0 || c > 32767 || multiplyCost(x, c, negative) > maxStrengthReduction {
+		return false
+	}
+
+	simple := isSimple(x)
+	stored := !simple && bits.OnesCount(uint(c)) > 1
+	operand := func() {
+		if stored {
+			jc.w.WritePush("temp", 1)
+		} else {
+			jc.CompileExpression(x)
+		}
+	}
+
+	jc.CompileExpression(x)
+	if stored {
+		jc.w.WritePop("temp", 1)
+		jc.w.WritePush("temp", 1)
+	}
+	for i := bits.Len(uint(c)) - 2; i >= 0; i-- {
+		if i == bits.Len(uint(c))-2 && (simple || stored) {
+			operand()
+		} else {
+			jc.w.WritePop("temp", 2)
+			jc.w.WritePush("temp", 2)
+			jc.w.WritePush("temp", 2)
+		}
+		jc.w.WriteArithmetic(token.PLUS)
+		if c>>i&1 == 1 {
+			operand()
+			jc.w.WriteArithmetic(token.PLUS)
+		}
+	}
+	if negative {
+		jc.w.WriteArithmetic("neg")
+	}
+	return true
+}
+
+// multiplyCost counts the instructions compileMultiply emits after computing x once
+func multiplyCost(x parseTree.Expression, c int, negative bool) int {
+	simple := isSimple(x)
+	stored := !simple && bits.OnesCount(uint(c)) > 1
+	cost := 0
+	if stored {
+		cost += 2
+	}
+	for i := bits.Len(uint(c)) - 2; i >= 0; i-- {
+		if i == bits.Len(uint(c))-2 && (simple || stored) {
+			cost += 2
+		} else {
+			cost += 4
+		}
+		if c>>i&1 == 1 {
+			cost += 2
+		}
+	}
+	if negative {
+		cost++
+	}
+	return cost
+}
+
+// isSimple reports whether x compiles to a single push that can be repeated
+func isSimple(x parseTree.Expression) bool {
+	switch x := x.(type) {
+	case *parseTree.Identifier:
+		return x.Indexer == nil
+	case *parseTree.IntegerConstant:
+		return true
+	}
+	return false
+}
@@ -1,11 +1,19 @@
 package jackCompiler
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/tivt2/jack-compiler/classIndex"
 	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/parser"
 	"github.com/tivt2/jack-compiler/symbolTable"
 	"github.com/tivt2/jack-compiler/token"
+	"github.com/tivt2/jack-compiler/tokenizer"
 	"github.com/tivt2/jack-compiler/vmWriter"
 )
 
@@ -17,7 +25,7 @@ func TestCompileExpression(t *testing.T) {
 		{
 			&parseTree.Prefix{
 				Operator:   token.Token{Type: token.MINUS, Literal: token.MINUS},
-				Expression: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
+				Expression: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "5"}, Value: 5},
 			},
 			"push constant 5\nneg\n",
 		},
@@ -33,9 +41,9 @@ func TestCompileExpression(t *testing.T) {
 				Operator: token.Token{Type: token.PLUS, Literal: token.PLUS},
 				Left: &parseTree.Prefix{
 					Operator:   token.Token{Type: token.MINUS, Literal: token.MINUS},
-					Expression: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
+					Expression: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "5"}, Value: 5},
 				},
-				Right: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
+				Right: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "5"}, Value: 5},
 			},
 			"push constant 5\nneg\npush constant 5\nadd\n",
 		},
@@ -44,9 +52,9 @@ func TestCompileExpression(t *testing.T) {
 				Operator: token.Token{Type: token.ASTERISK, Literal: token.ASTERISK},
 				Left: &parseTree.Prefix{
 					Operator:   token.Token{Type: token.MINUS, Literal: token.MINUS},
-					Expression: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
+					Expression: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "5"}, Value: 5},
 				},
-				Right: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "7"}, Value: 7},
+				Right: &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "7"}, Value: 7},
 			},
 			"push constant 5\nneg\npush constant 7\ncall Math.multiply 2\n",
 		},
@@ -61,7 +69,7 @@ func TestCompileExpression(t *testing.T) {
 					Value: "someFunction",
 				},
 				ExpList: []parseTree.Expression{
-					&parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					&parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "1"}, Value: 1},
 				},
 			},
 			"push constant 1\ncall SomeClass.someFunction 1\n",
@@ -79,7 +87,7 @@ func TestCompileExpression(t *testing.T) {
 						Value: "someFunction",
 					},
 					ExpList: []parseTree.Expression{
-						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "1"}, Value: 1},
 					},
 				},
 				Right: &parseTree.Identifier{Token: token.Token{Type: token.IDENT, Literal: "localVar"}, Value: "localVar"},
@@ -99,7 +107,7 @@ func TestCompileExpression(t *testing.T) {
 						Value: "someFunction",
 					},
 					ExpList: []parseTree.Expression{
-						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "1"}, Value: 1},
 					},
 				},
 				Right: &parseTree.Identifier{Token: token.Token{Type: token.IDENT, Literal: "localVar"}, Value: "localVar"},
@@ -111,9 +119,9 @@ func TestCompileExpression(t *testing.T) {
 	for _, test := range tests {
 		jc := &JackCompiler{w: vmWriter.New("testing.jack"), s: symbolTable.New()}
 
-		jc.s.Define("this", "SomeClass", "argument")
-		jc.s.Define("localVar", "int", "local")
-		jc.s.Define("instance", "SomeClass", "field")
+		jc.s.Define("this", "SomeClass", "argument", token.Span{})
+		jc.s.Define("localVar", "int", "local", token.Span{})
+		jc.s.Define("instance", "SomeClass", "field", token.Span{})
 
 		jc.CompileExpression(test.input)
 
@@ -134,8 +142,8 @@ func TestStatement(t *testing.T) {
 				Ident: &parseTree.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
 				Expression: &parseTree.Infix{
 					Operator: token.Token{Type: token.PLUS, Literal: token.PLUS},
-					Left:     &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
-					Right:    &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "10"}, Value: 10},
+					Left:     &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "5"}, Value: 5},
+					Right:    &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "10"}, Value: 10},
 				},
 			},
 			"push constant 5\npush constant 10\nadd\npop this 0\n",
@@ -154,7 +162,7 @@ func TestStatement(t *testing.T) {
 						Value: "new",
 					},
 					ExpList: []parseTree.Expression{
-						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "1"}, Value: 1},
 						&parseTree.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
 					},
 				},
@@ -168,14 +176,14 @@ func TestStatement(t *testing.T) {
 					Token: token.Token{Type: token.IDENT, Literal: "x"},
 					Value: "x",
 					Indexer: &parseTree.IntegerConstant{
-						Token: token.Token{Type: token.INT, Literal: "2"},
+						Token: token.Token{Type: token.INT_CONST, Literal: "2"},
 						Value: 2,
 					},
 				},
 				Expression: &parseTree.Infix{
 					Operator: token.Token{Type: token.PLUS, Literal: token.PLUS},
-					Left:     &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
-					Right:    &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "10"}, Value: 10},
+					Left:     &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "5"}, Value: 5},
+					Right:    &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "10"}, Value: 10},
 				},
 			},
 			"push this 0\npush constant 2\nadd\npush constant 5\npush constant 10\nadd\npop temp 0\npop pointer 1\npush temp 0\npop that 0\n",
@@ -187,7 +195,7 @@ func TestStatement(t *testing.T) {
 					Token: token.Token{Type: token.IDENT, Literal: "x"},
 					Value: "x",
 					Indexer: &parseTree.IntegerConstant{
-						Token: token.Token{Type: token.INT, Literal: "2"},
+						Token: token.Token{Type: token.INT_CONST, Literal: "2"},
 						Value: 2,
 					},
 				},
@@ -195,7 +203,7 @@ func TestStatement(t *testing.T) {
 					Token: token.Token{Type: token.IDENT, Literal: "x"},
 					Value: "x",
 					Indexer: &parseTree.IntegerConstant{
-						Token: token.Token{Type: token.INT, Literal: "5"},
+						Token: token.Token{Type: token.INT_CONST, Literal: "5"},
 						Value: 5,
 					},
 				},
@@ -207,8 +215,8 @@ func TestStatement(t *testing.T) {
 				Token: token.Token{Type: token.RETURN, Literal: token.RETURN},
 				Expression: &parseTree.Infix{
 					Operator: token.Token{Type: token.PLUS, Literal: token.PLUS},
-					Left:     &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5},
-					Right:    &parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "10"}, Value: 10},
+					Left:     &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "5"}, Value: 5},
+					Right:    &parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "10"}, Value: 10},
 				},
 			},
 			"push constant 5\npush constant 10\nadd\nreturn\n",
@@ -240,7 +248,7 @@ func TestStatement(t *testing.T) {
 						Value: "someFunction",
 					},
 					ExpList: []parseTree.Expression{
-						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+						&parseTree.IntegerConstant{Token: token.Token{Type: token.INT_CONST, Literal: "1"}, Value: 1},
 					},
 				},
 			},
@@ -256,7 +264,7 @@ func TestStatement(t *testing.T) {
 						Value: "x",
 					},
 					Right: &parseTree.IntegerConstant{
-						Token: token.Token{Type: token.INT, Literal: "0"},
+						Token: token.Token{Type: token.INT_CONST, Literal: "0"},
 						Value: 0,
 					},
 				},
@@ -274,7 +282,7 @@ func TestStatement(t *testing.T) {
 								Value: "x",
 							},
 							Right: &parseTree.IntegerConstant{
-								Token: token.Token{Type: token.INT, Literal: "1"},
+								Token: token.Token{Type: token.INT_CONST, Literal: "1"},
 								Value: 1,
 							},
 						},
@@ -293,7 +301,7 @@ func TestStatement(t *testing.T) {
 						Value: "x",
 					},
 					Right: &parseTree.IntegerConstant{
-						Token: token.Token{Type: token.INT, Literal: "0"},
+						Token: token.Token{Type: token.INT_CONST, Literal: "0"},
 						Value: 0,
 					},
 				},
@@ -317,7 +325,7 @@ func TestStatement(t *testing.T) {
 						Value: "x",
 					},
 					Right: &parseTree.IntegerConstant{
-						Token: token.Token{Type: token.INT, Literal: "0"},
+						Token: token.Token{Type: token.INT_CONST, Literal: "0"},
 						Value: 0,
 					},
 				},
@@ -341,7 +349,7 @@ func TestStatement(t *testing.T) {
 								Value: "x",
 							},
 							Right: &parseTree.IntegerConstant{
-								Token: token.Token{Type: token.INT, Literal: "1"},
+								Token: token.Token{Type: token.INT_CONST, Literal: "1"},
 								Value: 1,
 							},
 						},
@@ -355,9 +363,9 @@ func TestStatement(t *testing.T) {
 	for _, test := range tests {
 		jc := &JackCompiler{w: vmWriter.New("testing.jack"), s: symbolTable.New()}
 
-		jc.s.Define("this", "Something", "argument")
-		jc.s.Define("x", "int", "field")
-		jc.s.Define("p", "Point", "field")
+		jc.s.Define("this", "Something", "argument", token.Span{})
+		jc.s.Define("x", "int", "field", token.Span{})
+		jc.s.Define("p", "Point", "field", token.Span{})
 
 		jc.CompileStatement(test.input)
 
@@ -397,7 +405,7 @@ func TestCompileSubroutineDec(t *testing.T) {
 					},
 				},
 				SubroutineBody: &parseTree.SubroutineBody{
-					VarDecs: []*parseTree.VarDec{},
+					VarDecs: []parseTree.Declaration{},
 					Statements: []parseTree.Statement{
 						&parseTree.LetStatement{
 							Token: token.Token{Type: token.LET, Literal: token.LET},
@@ -443,7 +451,7 @@ func TestCompileSubroutineDec(t *testing.T) {
 				},
 				Params: []*parseTree.Param{},
 				SubroutineBody: &parseTree.SubroutineBody{
-					VarDecs: []*parseTree.VarDec{},
+					VarDecs: []parseTree.Declaration{},
 					Statements: []parseTree.Statement{
 						&parseTree.ReturnStatement{
 							Token: token.Token{Type: token.RETURN, Literal: token.RETURN},
@@ -489,8 +497,8 @@ func TestCompileSubroutineDec(t *testing.T) {
 					},
 				},
 				SubroutineBody: &parseTree.SubroutineBody{
-					VarDecs: []*parseTree.VarDec{
-						{
+					VarDecs: []parseTree.Declaration{
+						&parseTree.VarDec{
 							Kind:    token.Token{Type: token.VAR, Literal: token.VAR},
 							DecType: token.Token{Type: token.INT, Literal: token.INT},
 							Ident: &parseTree.Identifier{
@@ -542,8 +550,8 @@ func TestCompileSubroutineDec(t *testing.T) {
 			}},
 		}
 
-		jc.s.Define("x", "int", "field")
-		jc.s.Define("y", "int", "field")
+		jc.s.Define("x", "int", "field", token.Span{})
+		jc.s.Define("y", "int", "field", token.Span{})
 
 		jc.CompileSubroutineDec(test.input)
 
@@ -552,3 +560,360 @@ func TestCompileSubroutineDec(t *testing.T) {
 		}
 	}
 }
+
+func TestNewFileName(t *testing.T) {
+	tests := []struct {
+		file        string
+		nameByClass bool
+		expected    []string
+		output      string
+	}{
+		{"Bar.jack", false, nil, "Bar.vm"},
+		{"Foo.jack", false, []string{"Foo.jack:1:7: error: Class name does not match file name Foo.jack, expected class Foo, got class Bar (hint: rename the class or the file, or use -name-by-class to write Bar.vm)"}, ""},
+		{"Foo.jack", true, nil, "Bar.vm"},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, test.file)
+		c, diags := parser.New(tokenizer.NewFile(path, "class Bar {\n\tfunction void main() {\n\t\treturn;\n\t}\n}\n")).ParseClass()
+		if len(diags) != 0 {
+			t.Fatalf("ParseClass() returned diagnostics: %v", diags)
+		}
+
+		jc, diags := New(path, c, Options{NameByClass: test.nameByClass})
+		var received []string
+		for _, d := range diags {
+			received = append(received, strings.TrimPrefix(d.String(), dir+string(filepath.Separator)))
+		}
+		if !reflect.DeepEqual(received, test.expected) {
+			t.Fatalf("New(%s)\n\nexpected:\n%v\n\nreceived:\n%v", test.file, test.expected, received)
+		}
+		if test.output == "" {
+			continue
+		}
+
+		jc.Compile()
+		if _, err := os.Stat(filepath.Join(dir, test.output)); err != nil {
+			t.Fatalf("New(%s) expected output file %s: %v", test.file, test.output, err)
+		}
+	}
+}
+
+func TestStrengthReduction(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x * 2", "push argument 0\npush argument 0\nadd\n"},
+		{"4 * x", "push argument 0\npush argument 0\nadd\npop temp 2\npush temp 2\npush temp 2\nadd\n"},
+		{"x * 3", "push argument 0\npush argument 0\nadd\npush argument 0\nadd\n"},
+		{"x * -2", "push argument 0\npush argument 0\nadd\nneg\n"},
+		{"a[0] * 2", "push local 0\npush constant 0\nadd\npop pointer 1\npush that 0\npop temp 2\npush temp 2\npush temp 2\nadd\n"},
+		{"x * 255", "push argument 0\npush constant 255\ncall Math.multiply 2\n"},
+		{"x / 1", "push argument 0\n"},
+		{"x / -1", "push argument 0\nneg\n"},
+		{"x / 3", "push argument 0\npush constant 3\ncall Math.divide 2\n"},
+		{"x / 16384", "push argument 0\npush argument 0\npush constant 0\nlt\npush constant 16383\nand\nadd\npop temp 1\n" +
+			"push temp 1\npush constant 16384\nand\npush constant 0\ngt\npush constant 1\nand\n" +
+			"push temp 1\npush constant 0\nlt\npush constant 2\nneg\nand\nadd\n"},
+		{"x / ~32767", "push argument 0\npush constant 32767\nnot\ncall Math.divide 2\n"},
+	}
+
+	for _, test := range tests {
+		input := "class Main { function int f(int x) { var Array a; return " + test.input + "; } }"
+		class, diags := parser.New(tokenizer.New(input)).ParseClass()
+		if len(diags) != 0 {
+			t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+		}
+		ret := class.Subroutines()[0].SubroutineBody.Statements[0].(*parseTree.ReturnStatement)
+
+		jc := &JackCompiler{w: vmWriter.New("testing.jack"), s: symbolTable.New(), optimize: 1}
+		jc.s.Define("x", "int", "argument", token.Span{})
+		jc.s.Define("a", "Array", "local", token.Span{})
+		jc.CompileExpression(ret.Expression)
+
+		if jc.w.Out.String() != test.expected {
+			t.Fatalf("CompileExpression(%s)\n\nexpected:\n%s\n\nreceived:\n%s", test.input, test.expected, jc.w.Out.String())
+		}
+	}
+}
+
+func TestStrengthReductionValues(t *testing.T) {
+	for c := -300; c <= 300; c++ {
+		for _, x := range []int{0, 1, -1, 7, -13, 181, 255, 1000, -32768, 32767} {
+			for _, indexed := range []bool{false, true} {
+				jc := &JackCompiler{w: vmWriter.New("testing.jack"), s: symbolTable.New(), optimize: 1}
+				jc.s.Define("x", "int", "argument", token.Span{})
+				var operand parseTree.Expression = &parseTree.Identifier{Value: "x"}
+				if indexed {
+					operand = &parseTree.Identifier{Value: "x", Indexer: &parseTree.IntegerConstant{Value: 0}}
+				}
+				if !jc.compileMultiply(operand, c) {
+					continue
+				}
+
+				if received, expected := run(jc.w.Out.String(), x), int(int16(x*c)); received != expected {
+					t.Fatalf("compileMultiply(x, %d) with x = %d, expected: %d, received: %d\n%s", c, x, expected, received, jc.w.Out.String())
+				}
+			}
+		}
+	}
+}
+
+func TestDivideValues(t *testing.T) {
+	xs := []int{-32768, -32767, -16385, -16384, -16383, 16383, 16384, 16385, 32766, 32767}
+	for x := -600; x <= 600; x++ {
+		xs = append(xs, x)
+	}
+	for x := -32768; x <= 32767; x += 97 {
+		xs = append(xs, x)
+	}
+
+	for k := 1; k <= 14; k++ {
+		for _, c := range []int{1 << k, -(1 << k)} {
+			for _, indexed := range []bool{false, true} {
+				jc := &JackCompiler{w: vmWriter.New("testing.jack"), s: symbolTable.New(), optimize: 1}
+				jc.s.Define("x", "int", "argument", token.Span{})
+				var operand parseTree.Expression = &parseTree.Identifier{Value: "x"}
+				if indexed {
+					operand = &parseTree.Identifier{Value: "x", Indexer: &parseTree.IntegerConstant{Value: 0}}
+				}
+				if !jc.compileDivide(operand, c) {
+					t.Fatalf("compileDivide(x, %d) not lowered", c)
+				}
+
+				code := jc.w.Out.String()
+				for _, x := range xs {
+					if received, expected := run(code, x), int(int16(x/c)); received != expected {
+						t.Fatalf("compileDivide(x, %d) with x = %d, expected: %d, received: %d\n%s", c, x, expected, received, code)
+					}
+				}
+			}
+		}
+	}
+}
+
+// run evaluates straight line VM code where argument 0 and that 0 both hold x
+func run(code string, x int) int {
+	var stack []int
+	temp := make(map[string]int)
+	pop := func() int {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	for _, line := range strings.Split(strings.TrimSpace(code), "\n") {
+		f := strings.Fields(line)
+		switch {
+		case f[0] == "push" && (f[1] == "argument" || f[1] == "that"):
+			stack = append(stack, x)
+		case f[0] == "push" && f[1] == "constant":
+			n, _ := strconv.Atoi(f[2])
+			stack = append(stack, n)
+		case f[0] == "push":
+			stack = append(stack, temp[f[1]+f[2]])
+		case f[0] == "pop":
+			temp[f[1]+f[2]] = pop()
+		case f[0] == "add":
+			stack = append(stack, int(int16(pop()+pop())))
+		case f[0] == "neg":
+			stack = append(stack, int(int16(-pop())))
+		case f[0] == "and":
+			stack = append(stack, pop()&pop())
+		case f[0] == "lt" || f[0] == "gt":
+			r, l := pop(), pop()
+			if f[0] == "gt" {
+				l, r = r, l
+			}
+			v := 0
+			if l < r {
+				v = -1
+			}
+			stack = append(stack, v)
+		}
+	}
+	return pop()
+}
+
+func TestShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"if ((i < n) & (a[i] = 0)) { let i = 1; }",
+			"push local 0\npush argument 0\nlt\nnot\nif-goto ELSE0\n" +
+				"push local 1\npush local 0\nadd\npop pointer 1\npush that 0\npush constant 0\neq\nnot\nif-goto ELSE0\n" +
+				"push constant 1\npop local 0\nlabel ELSE0\n",
+		},
+		{
+			"while ((i = 0) | ((i > n) & ~(i = 5))) { let i = 1; }",
+			"label WHILE0\npush local 0\npush constant 0\neq\nif-goto COND0\n" +
+				"push local 0\npush argument 0\ngt\nnot\nif-goto BREAK0\n" +
+				"push local 0\npush constant 5\neq\nnot\nnot\nif-goto BREAK0\nlabel COND0\n" +
+				"push constant 1\npop local 0\ngoto WHILE0\nlabel BREAK0\n",
+		},
+		{
+			"if (~((i = 0) | (i = n))) { let i = 1; }",
+			"push local 0\npush constant 0\neq\npush local 0\npush argument 0\neq\nor\nnot\nnot\nif-goto ELSE0\n" +
+				"push constant 1\npop local 0\nlabel ELSE0\n",
+		},
+		{
+			"if ((i < n) & b) { let i = 1; }",
+			"push local 0\npush argument 0\nlt\nnot\nif-goto ELSE0\npush local 2\nnot\nif-goto ELSE0\n" +
+				"push constant 1\npop local 0\nlabel ELSE0\n",
+		},
+		{
+			"if (Main.g() | (i = 0)) { let i = 1; }",
+			"call Main.g 0\nif-goto COND0\npush local 0\npush constant 0\neq\nnot\nif-goto ELSE0\nlabel COND0\n" +
+				"push constant 1\npop local 0\nlabel ELSE0\n",
+		},
+		{
+			"if (i | (i = 0)) { let i = 1; }",
+			"push local 0\npush local 0\npush constant 0\neq\nor\nnot\nif-goto ELSE0\n" +
+				"push constant 1\npop local 0\nlabel ELSE0\n",
+		},
+		{
+			"if ((i & n) & (i > 0)) { let i = 1; }",
+			"push local 0\npush argument 0\nand\npush local 0\npush constant 0\ngt\nand\nnot\nif-goto ELSE0\n" +
+				"push constant 1\npop local 0\nlabel ELSE0\n",
+		},
+		{
+			"let b = (i < n) & (i > 0);",
+			"push local 0\npush argument 0\nlt\npush local 0\npush constant 0\ngt\nand\npop local 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		input := "class Main { function void f(int n) { var int i; var Array a; var boolean b; " + test.input + " return; } function boolean g() { return true; } }"
+		class, diags := parser.New(tokenizer.New(input)).ParseClass()
+		if len(diags) != 0 {
+			t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+		}
+
+		idx := classIndex.New()
+		idx.Add(class)
+		jc := &JackCompiler{w: vmWriter.New("testing.jack"), s: symbolTable.New(), c: class, idx: idx, shortCircuit: true}
+		jc.s.Define("n", "int", "argument", token.Span{})
+		jc.s.Define("i", "int", "local", token.Span{})
+		jc.s.Define("a", "Array", "local", token.Span{})
+		jc.s.Define("b", "boolean", "local", token.Span{})
+		jc.CompileStatement(class.Subroutines()[0].SubroutineBody.Statements[0])
+
+		if jc.w.Out.String() != test.expected {
+			t.Fatalf("CompileStatement(%s)\n\nexpected:\n%s\n\nreceived:\n%s", test.input, test.expected, jc.w.Out.String())
+		}
+	}
+}
+
+func TestReference(t *testing.T) {
+	input := `class Main {
+	function void main() {
+		var Array a;
+		var int i;
+		var boolean ok;
+		do Output.printInt(1 + (2 * 3));
+		let a = Array.new(3);
+		let i = 0;
+		while (i < 3) {
+			let a[i] = i;
+			let i = i + 1;
+		}
+		if (a[1] = 1) { let ok = true; } else { let ok = false; }
+		return;
+	}
+	function int sign(int x) {
+		if (x < 0) { return -1; }
+		return 1;
+	}
+}`
+	expected := `function Main.main 3
+push constant 1
+push constant 2
+push constant 3
+call Math.multiply 2
+add
+call Output.printInt 1
+pop temp 0
+push constant 3
+call Array.new 1
+pop local 0
+push constant 0
+pop local 1
+label WHILE_EXP0
+push local 1
+push constant 3
+lt
+not
+if-goto WHILE_END0
+push local 1
+push local 0
+add
+push local 1
+pop temp 0
+pop pointer 1
+push temp 0
+pop that 0
+push local 1
+push constant 1
+add
+pop local 1
+goto WHILE_EXP0
+label WHILE_END0
+push constant 1
+push local 0
+add
+pop pointer 1
+push that 0
+push constant 1
+eq
+if-goto IF_TRUE0
+goto IF_FALSE0
+label IF_TRUE0
+push constant 0
+not
+pop local 2
+goto IF_END0
+label IF_FALSE0
+push constant 0
+pop local 2
+label IF_END0
+push constant 0
+return
+function Main.sign 0
+push argument 0
+push constant 0
+lt
+if-goto IF_TRUE0
+goto IF_FALSE0
+label IF_TRUE0
+push constant 1
+neg
+return
+label IF_FALSE0
+push constant 1
+return
+`
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Main.jack")
+	class, diags := parser.New(tokenizer.NewFile(path, input)).ParseClass()
+	if len(diags) != 0 {
+		t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+	}
+
+	jc, diags := New(path, class, Options{Reference: true, Optimize: 2, ShortCircuit: true})
+	if jc == nil {
+		t.Fatalf("New() unexpected diagnostics: %v", diags)
+	}
+	jc.Compile()
+
+	out, err := os.ReadFile(filepath.Join(dir, "Main.vm"))
+	if err != nil {
+		t.Fatalf("Compile() expected Main.vm: %v", err)
+	}
+	if string(out) != expected {
+		t.Fatalf("Compile()\n\nexpected:\n%s\n\nreceived:\n%s", expected, out)
+	}
+}
@@ -0,0 +1,164 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/symbolTable"
+	"github.com/tivt2/jack-compiler/token"
+)
+
+const Suppression = "lint:ignore"
+
+type Rule interface {
+	Name() string
+	Check(ctx *Context)
+}
+
+type RuleConfig struct {
+	Enabled  *bool               `json:"enabled,omitempty"`
+	Severity diagnostic.Severity `json:"severity,omitempty"`
+	Max      int                 `json:"max,omitempty"`
+	Allow    []int               `json:"allow,omitempty"`
+}
+
+type Config struct {
+	Rules map[string]RuleConfig `json:"rules"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(file, &config); err != nil {
+		return config, fmt.Errorf("invalid lint config %s: %w", path, err)
+	}
+	known := make(map[string]bool)
+	for _, rule := range DefaultRules() {
+		known[rule.Name()] = true
+	}
+	names := make([]string, 0, len(config.Rules))
+	for name := range config.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return config, fmt.Errorf("invalid lint config %s: unknown rule %q", path, name)
+		}
+		rc := config.Rules[name]
+		switch rc.Severity {
+		case "", diagnostic.ERROR, diagnostic.WARNING, diagnostic.INFO:
+		default:
+			return config, fmt.Errorf("invalid lint config %s: rule %s has unknown severity %q", path, name, rc.Severity)
+		}
+	}
+	return config, nil
+}
+
+type Linter struct {
+	rules  []Rule
+	config Config
+}
+
+func New(config Config) *Linter {
+	l := &Linter{config: config}
+	for _, rule := range DefaultRules() {
+		l.Register(rule)
+	}
+	return l
+}
+
+func (l *Linter) Register(rule Rule) {
+	l.rules = append(l.rules, rule)
+}
+
+func (l *Linter) Lint(c *parseTree.Class) []diagnostic.Diagnostic {
+	var diags []diagnostic.Diagnostic
+	for _, rule := range l.rules {
+		rc := l.config.Rules[rule.Name()]
+		if rc.Enabled != nil && !*rc.Enabled {
+			continue
+		}
+		ctx := &Context{Class: c, rule: rule.Name(), config: rc}
+		rule.Check(ctx)
+		diags = append(diags, ctx.diagnostics...)
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Start.Offset < diags[j].Start.Offset
+	})
+	return diags
+}
+
+type Context struct {
+	Class *parseTree.Class
+
+	rule        string
+	config      RuleConfig
+	diagnostics []diagnostic.Diagnostic
+}
+
+func (ctx *Context) Max(fallback int) int {
+	if ctx.config.Max > 0 {
+		return ctx.config.Max
+	}
+	return fallback
+}
+
+func (ctx *Context) Allow(fallback []int) []int {
+	if ctx.config.Allow != nil {
+		return ctx.config.Allow
+	}
+	return fallback
+}
+
+func (ctx *Context) Symbols(sd *parseTree.SubroutineDec) *symbolTable.SymbolTable {
+	s := symbolTable.New()
+	s.DefineClass(ctx.Class)
+	if sd != nil {
+		s.DefineSubroutine(ctx.Class, sd)
+	}
+	return s
+}
+
+func (ctx *Context) Report(span token.Span, msg string, suggestion string) {
+	if ctx.suppressed(span.Start.Line) {
+		return
+	}
+	severity := ctx.config.Severity
+	if severity == "" {
+		severity = diagnostic.WARNING
+	}
+	ctx.diagnostics = append(ctx.diagnostics, diagnostic.Diagnostic{
+		Severity:   severity,
+		Message:    fmt.Sprintf("%s [%s]", msg, ctx.rule),
+		Suggestion: suggestion,
+		Span:       span,
+	})
+}
+
+func (ctx *Context) suppressed(line int) bool {
+	for _, text := range ctx.Class.Annotations(line) {
+		if !strings.HasPrefix(text, Suppression) {
+			continue
+		}
+		for _, name := range strings.FieldsFunc(text[len(Suppression):], isSeparator) {
+			if name == ctx.rule || name == "all" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t'
+}
@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+const input = `class game {
+	static int MAX_SCORE;
+	field int best_score;
+
+	function void Init() {
+		let MAX_SCORE = 500;
+		return;
+	}
+
+	method void play(int rounds) {
+		var int i;
+		let i = 0;
+		while (i < rounds) {
+			if (i > 0) {
+				if (i < 5) {
+					if (i = 3) {
+						do Output.printString("three");
+					}
+				}
+			}
+			let best_score = best_score + 100; // lint:ignore magic-number
+			let i = i + 1;
+		}
+		do Output.printString("done");
+		return;
+	}
+}`
+
+func lintInput(t *testing.T, config Config) []string {
+	tkzr := tokenizer.New(input)
+	p := parser.New(tkzr)
+	class, diags := p.ParseClass()
+	if len(diags) != 0 {
+		t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+	}
+
+	var out []string
+	for _, d := range New(config).Lint(class) {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+func TestLint(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		config   Config
+		expected []string
+	}{
+		{
+			Config{},
+			[]string{
+				"1:7: warning: Class name 'game' should be capitalised [naming] (hint: rename it to 'Game')",
+				"3:12: warning: Variable name 'best_score' should be camelCase [naming]",
+				"5:16: warning: Subroutine name 'Init' should be camelCase [naming]",
+				"15:13: warning: Magic number 5 [magic-number] (hint: give it a name by assigning it to a static)",
+				"16:6: warning: Statement nested 4 levels deep [deep-nesting] (hint: extract the inner block into a subroutine, the limit is 3 levels)",
+				"16:14: warning: Magic number 3 [magic-number] (hint: give it a name by assigning it to a static)",
+				"17:29: warning: String constant allocated inside a loop [string-in-loop] (hint: every evaluation calls String.new and is never disposed, create it once before the loop)",
+			},
+		},
+		{
+			Config{Rules: map[string]RuleConfig{
+				"naming":          {Enabled: &disabled},
+				"magic-number":    {Allow: []int{3, 5}},
+				"deep-nesting":    {Severity: "error", Max: 4},
+				"long-subroutine": {Severity: "info", Max: 10},
+			}},
+			[]string{
+				"10:14: info: Subroutine game.play is 17 lines long [long-subroutine] (hint: split it into smaller subroutines, the limit is 10 lines)",
+				"12:11: warning: Magic number 0 [magic-number] (hint: give it a name by assigning it to a static)",
+				"14:12: warning: Magic number 0 [magic-number] (hint: give it a name by assigning it to a static)",
+				"17:29: warning: String constant allocated inside a loop [string-in-loop] (hint: every evaluation calls String.new and is never disposed, create it once before the loop)",
+				"22:16: warning: Magic number 1 [magic-number] (hint: give it a name by assigning it to a static)",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		received := lintInput(t, test.config)
+		if len(received) != len(test.expected) {
+			t.Fatalf("Lint() test index %d, expected %d diagnostics, received: %v", i, len(test.expected), received)
+		}
+		for j := range received {
+			if received[j] != test.expected[j] {
+				t.Fatalf("Lint() test index %d, expected: %s, received: %s", i, test.expected[j], received[j])
+			}
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"rules": {"magic-number": {"allow": [0, 1, 10]}, "naming": {"severity": "info"}}}`, ""},
+		{`{"rules": {"magic-numbers": {"enabled": false}}}`, `unknown rule "magic-numbers"`},
+		{`{"rules": {"naming": {"severity": "fatal"}}}`, `rule naming has unknown severity "fatal"`},
+		{`{"rules": [}`, "invalid lint config"},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(t.TempDir(), "jacklint.json")
+		if err := os.WriteFile(path, []byte(test.input), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadConfig(path)
+		switch {
+		case test.expected == "" && err != nil:
+			t.Fatalf("LoadConfig() test index %d, unexpected error: %v", i, err)
+		case test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)):
+			t.Fatalf("LoadConfig() test index %d, expected error containing %q, received: %v", i, test.expected, err)
+		}
+	}
+}
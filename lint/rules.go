@@ -0,0 +1,191 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/symbolTable"
+)
+
+func DefaultRules() []Rule {
+	return []Rule{
+		&naming{},
+		&longSubroutine{},
+		&deepNesting{},
+		&magicNumber{},
+		&stringInLoop{},
+	}
+}
+
+type naming struct{}
+
+func (r *naming) Name() string { return "naming" }
+
+func (r *naming) Check(ctx *Context) {
+	c := ctx.Class
+	if name := c.Ident.Value; !isUpper(name[0]) {
+		ctx.Report(c.Ident.Span, fmt.Sprintf("Class name '%s' should be capitalised", name), fmt.Sprintf("rename it to '%s'", strings.ToUpper(name[:1])+name[1:]))
+	}
+
+	r.checkSymbols(ctx, ctx.Symbols(nil).ClassSymbols())
+	for _, sd := range c.Subroutines() {
+		if !isCamelCase(sd.Ident.Value) {
+			ctx.Report(sd.Ident.Span, fmt.Sprintf("Subroutine name '%s' should be camelCase", sd.Ident.Value), "")
+		}
+		r.checkSymbols(ctx, ctx.Symbols(sd).SubroutineSymbols())
+	}
+}
+
+func (r *naming) checkSymbols(ctx *Context, symbols []symbolTable.Symbol) {
+	for _, sym := range symbols {
+		if isCamelCase(sym.Name) || sym.Kind == "static" && strings.ToUpper(sym.Name) == sym.Name {
+			continue
+		}
+		ctx.Report(sym.Span, fmt.Sprintf("Variable name '%s' should be camelCase", sym.Name), "")
+	}
+}
+
+type longSubroutine struct{}
+
+func (r *longSubroutine) Name() string { return "long-subroutine" }
+
+func (r *longSubroutine) Check(ctx *Context) {
+	max := ctx.Max(50)
+	for _, sd := range ctx.Class.Subroutines() {
+		if lines := sd.End.Line - sd.Start.Line + 1; lines > max {
+			ctx.Report(sd.Ident.Span, fmt.Sprintf("Subroutine %s.%s is %d lines long", ctx.Class.Ident.Value, sd.Ident.Value, lines), fmt.Sprintf("split it into smaller subroutines, the limit is %d lines", max))
+		}
+	}
+}
+
+type deepNesting struct{}
+
+func (r *deepNesting) Name() string { return "deep-nesting" }
+
+func (r *deepNesting) Check(ctx *Context) {
+	max := ctx.Max(3)
+	for _, sd := range ctx.Class.Subroutines() {
+		walk(sd.SubroutineBody.Statements, scope{}, func(node parseTree.Node, sc scope) {
+			switch node.(type) {
+			case *parseTree.IfStatement, *parseTree.WhileStatement:
+				if sc.depth == max {
+					ctx.Report(node.Range(), fmt.Sprintf("Statement nested %d levels deep", sc.depth+1), fmt.Sprintf("extract the inner block into a subroutine, the limit is %d levels", max))
+				}
+			}
+		})
+	}
+}
+
+type magicNumber struct{}
+
+func (r *magicNumber) Name() string { return "magic-number" }
+
+func (r *magicNumber) Check(ctx *Context) {
+	allowed := make(map[int]bool)
+	for _, n := range ctx.Allow([]int{0, 1, 2}) {
+		allowed[n] = true
+	}
+
+	for _, sd := range ctx.Class.Subroutines() {
+		s := ctx.Symbols(sd)
+		constants := make(map[parseTree.Node]bool)
+		walk(sd.SubroutineBody.Statements, scope{}, func(node parseTree.Node, sc scope) {
+			switch node := node.(type) {
+			case *parseTree.LetStatement:
+				if node.Ident.Indexer == nil && s.KindOf(node.Ident.Value) == "static" {
+					constants[unwrap(node.Expression)] = true
+				}
+			case *parseTree.IntegerConstant:
+				if !allowed[node.Value] && !constants[node] {
+					ctx.Report(node.Span, fmt.Sprintf("Magic number %d", node.Value), "give it a name by assigning it to a static")
+				}
+			}
+		})
+	}
+}
+
+type stringInLoop struct{}
+
+func (r *stringInLoop) Name() string { return "string-in-loop" }
+
+func (r *stringInLoop) Check(ctx *Context) {
+	for _, sd := range ctx.Class.Subroutines() {
+		walk(sd.SubroutineBody.Statements, scope{}, func(node parseTree.Node, sc scope) {
+			if _, ok := node.(*parseTree.StringConstant); ok && sc.loops > 0 {
+				ctx.Report(node.Range(), "String constant allocated inside a loop", "every evaluation calls String.new and is never disposed, create it once before the loop")
+			}
+		})
+	}
+}
+
+type scope struct {
+	depth int
+	loops int
+}
+
+func walk(stmts []parseTree.Statement, sc scope, visit func(node parseTree.Node, sc scope)) {
+	inner := scope{depth: sc.depth + 1, loops: sc.loops}
+	for _, stmt := range stmts {
+		visit(stmt, sc)
+		switch stmt := stmt.(type) {
+		case *parseTree.LetStatement:
+			if stmt.Ident.Indexer != nil {
+				walkExpression(stmt.Ident.Indexer, sc, visit)
+			}
+			walkExpression(stmt.Expression, sc, visit)
+		case *parseTree.ReturnStatement:
+			if stmt.Expression != nil {
+				walkExpression(stmt.Expression, sc, visit)
+			}
+		case *parseTree.DoStatement:
+			walkExpression(stmt.Expression, sc, visit)
+		case *parseTree.WhileStatement:
+			walkExpression(stmt.Expression, scope{depth: sc.depth, loops: sc.loops + 1}, visit)
+			walk(stmt.Stmts, scope{depth: inner.depth, loops: sc.loops + 1}, visit)
+		case *parseTree.IfStatement:
+			walkExpression(stmt.Expression, sc, visit)
+			walk(stmt.IfStmts, inner, visit)
+			walk(stmt.Else, inner, visit)
+		}
+	}
+}
+
+func walkExpression(exp parseTree.Expression, sc scope, visit func(node parseTree.Node, sc scope)) {
+	visit(exp, sc)
+	switch exp := exp.(type) {
+	case *parseTree.Prefix:
+		walkExpression(exp.Expression, sc, visit)
+	case *parseTree.Group:
+		walkExpression(exp.Expression, sc, visit)
+	case *parseTree.Infix:
+		walkExpression(exp.Left, sc, visit)
+		walkExpression(exp.Right, sc, visit)
+	case *parseTree.Identifier:
+		if exp.Indexer != nil {
+			walkExpression(exp.Indexer, sc, visit)
+		}
+	case *parseTree.SubroutineCall:
+		for _, e := range exp.ExpList {
+			walkExpression(e, sc, visit)
+		}
+	}
+}
+
+func unwrap(exp parseTree.Expression) parseTree.Expression {
+	switch e := exp.(type) {
+	case *parseTree.Group:
+		return unwrap(e.Expression)
+	case *parseTree.Prefix:
+		return unwrap(e.Expression)
+	}
+	return exp
+}
+
+func isUpper(ch byte) bool {
+	return 'A' <= ch && ch <= 'Z'
+}
+
+func isCamelCase(name string) bool {
+	return 'a' <= name[0] && name[0] <= 'z' && !strings.Contains(name, "_")
+}
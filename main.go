@@ -1,52 +1,252 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/tivt2/jack-compiler/classIndex"
+	"github.com/tivt2/jack-compiler/diagnostic"
 	"github.com/tivt2/jack-compiler/jackCompiler"
+	"github.com/tivt2/jack-compiler/lint"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/syntaxAnalyzer"
+	"github.com/tivt2/jack-compiler/typeChecker"
+	"github.com/tivt2/jack-compiler/xmlWriter"
 )
 
+const usage = "Usage 'JackCompiler [-tokens | -tree] [-strict] [-strict-char] [-name-by-class] [-short-circuit] [-reference] [-O level] [-peephole-disable rules] [-stats] [-lint] [-lint-config file] <filename.jack | foldername>'"
+
+var tokensMode = flag.Bool("tokens", false, "write xxxT.xml token files instead of compiling")
+var treeMode = flag.Bool("tree", false, "write xxx.xml parse tree files instead of compiling")
+var strict = flag.Bool("strict", false, "report type mismatches as errors instead of warnings")
+var strictChar = flag.Bool("strict-char", false, "do not allow int and char to be used interchangeably")
+var nameByClass = flag.Bool("name-by-class", false, "name each .vm file after its class instead of its source file")
+var shortCircuit = flag.Bool("short-circuit", false, "stop evaluating & and | in if and while conditions once the result is known")
+var reference = flag.Bool("reference", false, "generate the same VM code as the official JackCompiler, ignores -O and -short-circuit")
+var optimize = flag.Int("O", 1, "optimization level, 0 disables, 1 folds constants, 2 also runs the peephole pass")
+var peepholeDisable = flag.String("peephole-disable", "", "comma separated peephole rules to skip")
+var stats = flag.Bool("stats", false, "print the instruction count of each .vm file before and after the peephole pass")
+var lintMode = flag.Bool("lint", false, "run the lint rules while compiling")
+var lintConfig = flag.String("lint-config", "", "lint config file, defaults to jacklint.json next to the sources")
+
 func main() {
-	if len(os.Args) == 0 {
-		log.Fatal("1 Usage 'JackCompiler <filename.jack | foldername>'")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal("1 " + usage)
+	}
+	path := flag.Arg(0)
+
+	files := jackFiles(path)
+	opts := jackCompiler.Options{NameByClass: *nameByClass, Optimize: *optimize, ShortCircuit: *shortCircuit, Reference: *reference}
+	opts.TypeCheck.StrictChar = *strictChar
+	if *strict {
+		opts.TypeCheck.Strictness = typeChecker.STRICT
+	}
+	if *peepholeDisable != "" {
+		opts.Peephole.Disabled = strings.Split(*peepholeDisable, ",")
+	}
+	if *lintMode {
+		opts.Lint = lint.New(loadLintConfig(path))
+	}
+
+	var ok bool
+	switch {
+	case *tokensMode:
+		ok = parallel(len(files), func(i int) bool { return writeTokens(files[i]) })
+	case *treeMode:
+		ok = parallel(len(files), func(i int) bool { return writeTree(files[i]) })
+	default:
+		ok = compileAll(files, siblingFiles(path), opts)
+	}
+	if !ok {
+		os.Exit(1)
 	}
-	path := os.Args[1]
+}
+
+func parallel(n int, fn func(i int) bool) bool {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := true
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			if !fn(i) {
+				mu.Lock()
+				ok = false
+				mu.Unlock()
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	return ok
+}
 
+func jackFiles(path string) []string {
 	if filepath.Ext(path) == ".jack" {
-		jc := jackCompiler.New(path)
-		jc.Compile()
-		return
+		return []string{path}
 	}
 
 	info, err := os.Stat(path)
 	checkErr(err, "checking file stats")
-	if info.IsDir() {
-		folder, err := os.Open(path)
-		checkErr(err, "error opening folder")
-		files, err2 := folder.Readdirnames(0)
-		checkErr(err2, "error reading folder files")
-
-		var wg sync.WaitGroup
-		for _, file := range files {
-			file := file
-			if filepath.Ext(file) == ".jack" {
-				wg.Add(1)
-				go func() {
-					jc := jackCompiler.New(filepath.Join(path, file))
-					jc.Compile()
-					wg.Done()
-				}()
+	if !info.IsDir() {
+		log.Fatal("2 " + usage)
+	}
+
+	folder, err := os.Open(path)
+	checkErr(err, "error opening folder")
+	names, err2 := folder.Readdirnames(0)
+	checkErr(err2, "error reading folder files")
+
+	var files []string
+	for _, name := range names {
+		if filepath.Ext(name) == ".jack" {
+			files = append(files, filepath.Join(path, name))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// siblingFiles returns the other .jack files next to a single compiled file, so the classes they declare are known
+func siblingFiles(path string) []string {
+	if filepath.Ext(path) != ".jack" {
+		return nil
+	}
+
+	var siblings []string
+	for _, file := range jackFiles(filepath.Dir(path)) {
+		if file != filepath.Clean(path) {
+			siblings = append(siblings, file)
+		}
+	}
+	return siblings
+}
+
+func loadLintConfig(path string) lint.Config {
+	configPath := *lintConfig
+	if configPath == "" {
+		dir := path
+		if filepath.Ext(path) == ".jack" {
+			dir = filepath.Dir(path)
+		}
+		configPath = filepath.Join(dir, "jacklint.json")
+		if _, err := os.Stat(configPath); err != nil {
+			return lint.Config{}
+		}
+	}
+
+	config, err := lint.LoadConfig(configPath)
+	checkErr(err, "loading lint config")
+	return config
+}
+
+func compileAll(files []string, siblings []string, opts jackCompiler.Options) bool {
+	classes := make([]*parseTree.Class, len(files))
+	ok := parallel(len(files), func(i int) bool {
+		class, diags := syntaxAnalyzer.ParseTree(files[i])
+		report(diags)
+		if diagnostic.HasErrors(diags) {
+			return false
+		}
+		classes[i] = class
+		return true
+	})
+	if !ok {
+		return false
+	}
+
+	opts.Index = classIndex.New()
+	for _, class := range classes {
+		if diags := opts.Index.Add(class); diags != nil {
+			report(diags)
+			ok = false
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	siblingClasses := make([]*parseTree.Class, len(siblings))
+	siblingDiags := make([]*diagnostic.Diagnostic, len(siblings))
+	parallel(len(siblings), func(i int) bool {
+		class, diags := syntaxAnalyzer.ParseTree(siblings[i])
+		for _, d := range diags {
+			if d.Severity == diagnostic.ERROR {
+				siblingDiags[i] = &diagnostic.Diagnostic{
+					Severity:   diagnostic.WARNING,
+					Message:    fmt.Sprintf("Sibling file is left out of the class index, it does not parse: %s", d.Message),
+					Suggestion: "compile the directory to see all of its errors",
+					Span:       d.Span,
+				}
+				return true
 			}
 		}
-		wg.Wait()
-		return
+		siblingClasses[i] = class
+		return true
+	})
+	for i, class := range siblingClasses {
+		if class != nil {
+			opts.Index.Add(class)
+		} else {
+			report([]diagnostic.Diagnostic{*siblingDiags[i]})
+		}
 	}
 
-	log.Fatal("2 Usage 'JackCompiler <filename.jack | foldername>'")
+	return parallel(len(files), func(i int) bool { return compile(files[i], classes[i], opts) })
+}
 
+func compile(filePath string, class *parseTree.Class, opts jackCompiler.Options) bool {
+	jc, diags := jackCompiler.New(filePath, class, opts)
+	report(diags)
+	if jc == nil {
+		return false
+	}
+	jc.Compile()
+	if *stats {
+		s := jc.Stats()
+		fmt.Printf("%s.vm: %d -> %d instructions\n", class.Ident.Value, s.Before, s.After)
+	}
+	return true
+}
+
+func writeTokens(filePath string) bool {
+	tokens, diags := syntaxAnalyzer.Tokens(filePath)
+	report(diags)
+	if diagnostic.HasErrors(diags) {
+		return false
+	}
+
+	w := xmlWriter.New(filePath[:len(filePath)-5] + "T.xml")
+	w.WriteTokens(tokens)
+	w.Close()
+	return true
+}
+
+func writeTree(filePath string) bool {
+	class, diags := syntaxAnalyzer.ParseTree(filePath)
+	report(diags)
+	if diagnostic.HasErrors(diags) {
+		return false
+	}
+
+	w := xmlWriter.New(filePath[:len(filePath)-5] + ".xml")
+	w.WriteClass(class)
+	w.Close()
+	return true
+}
+
+func report(diags []diagnostic.Diagnostic) {
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d)
+	}
 }
 
 func checkErr(err error, msg string) {
@@ -0,0 +1,250 @@
+package optimizer
+
+import (
+	"strconv"
+
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/token"
+)
+
+type Optimizer struct {
+	c *parseTree.Class
+}
+
+func New(c *parseTree.Class) *Optimizer {
+	return &Optimizer{c: c}
+}
+
+func Optimize(c *parseTree.Class) {
+	New(c).Optimize()
+}
+
+func (o *Optimizer) Optimize() {
+	for _, sd := range o.c.Subroutines() {
+		o.optimizeStatements(sd.SubroutineBody.Statements)
+	}
+}
+
+func (o *Optimizer) optimizeStatements(stmts []parseTree.Statement) {
+	for _, stmt := range stmts {
+		o.optimizeStatement(stmt)
+	}
+}
+
+func (o *Optimizer) optimizeStatement(stmt parseTree.Statement) {
+	switch stmt := stmt.(type) {
+	case *parseTree.LetStatement:
+		if stmt.Ident.Indexer != nil {
+			stmt.Ident.Indexer = o.OptimizeExpression(stmt.Ident.Indexer)
+		}
+		stmt.Expression = o.OptimizeExpression(stmt.Expression)
+	case *parseTree.ReturnStatement:
+		if stmt.Expression != nil {
+			stmt.Expression = o.OptimizeExpression(stmt.Expression)
+		}
+	case *parseTree.DoStatement:
+		stmt.Expression = o.OptimizeExpression(stmt.Expression)
+	case *parseTree.WhileStatement:
+		stmt.Expression = o.OptimizeExpression(stmt.Expression)
+		o.optimizeStatements(stmt.Stmts)
+	case *parseTree.IfStatement:
+		stmt.Expression = o.OptimizeExpression(stmt.Expression)
+		o.optimizeStatements(stmt.IfStmts)
+		o.optimizeStatements(stmt.Else)
+	}
+}
+
+func (o *Optimizer) OptimizeExpression(exp parseTree.Expression) parseTree.Expression {
+	switch exp := exp.(type) {
+	case *parseTree.Group:
+		return o.OptimizeExpression(exp.Expression)
+	case *parseTree.Prefix:
+		exp.Expression = o.OptimizeExpression(exp.Expression)
+		return o.foldPrefix(exp)
+	case *parseTree.Infix:
+		exp.Left = o.OptimizeExpression(exp.Left)
+		exp.Right = o.OptimizeExpression(exp.Right)
+		return o.foldInfix(exp)
+	case *parseTree.Identifier:
+		if exp.Indexer != nil {
+			exp.Indexer = o.OptimizeExpression(exp.Indexer)
+		}
+	case *parseTree.SubroutineCall:
+		for i, e := range exp.ExpList {
+			exp.ExpList[i] = o.OptimizeExpression(e)
+		}
+	}
+	return exp
+}
+
+func (o *Optimizer) foldPrefix(exp *parseTree.Prefix) parseTree.Expression {
+	if v, ok := Value(exp.Expression); ok {
+		if exp.Operator.Type == token.MINUS {
+			return constant(-v, exp.Span)
+		}
+		return constant(^v, exp.Span)
+	}
+	if inner, ok := exp.Expression.(*parseTree.Prefix); ok && inner.Operator.Type == exp.Operator.Type {
+		return inner.Expression
+	}
+	return exp
+}
+
+func (o *Optimizer) foldInfix(exp *parseTree.Infix) parseTree.Expression {
+	l, lok := Value(exp.Left)
+	r, rok := Value(exp.Right)
+	if lok && rok {
+		if v, ok := evaluate(exp.Operator.Type, l, r); ok {
+			return constant(v, exp.Span)
+		}
+		return exp
+	}
+
+	switch exp.Operator.Type {
+	case token.PLUS:
+		switch {
+		case rok && r == 0:
+			return exp.Left
+		case lok && l == 0:
+			return exp.Right
+		}
+	case token.MINUS:
+		switch {
+		case rok && r == 0:
+			return exp.Left
+		case lok && l == 0:
+			return o.foldPrefix(&parseTree.Prefix{Span: exp.Span, Operator: token.Token{Type: token.MINUS, Literal: token.MINUS}, Expression: exp.Right})
+		}
+	case token.ASTERISK:
+		switch {
+		case rok && r == 1:
+			return exp.Left
+		case lok && l == 1:
+			return exp.Right
+		case rok && r == 0 && pure(exp.Left), lok && l == 0 && pure(exp.Right):
+			return constant(0, exp.Span)
+		}
+	case token.FSLASH:
+		if rok && r == 1 {
+			return exp.Left
+		}
+	case token.AMP:
+		switch {
+		case rok && r == -1:
+			return exp.Left
+		case lok && l == -1:
+			return exp.Right
+		case rok && r == 0 && pure(exp.Left), lok && l == 0 && pure(exp.Right):
+			return constant(0, exp.Span)
+		}
+	case token.BAR:
+		switch {
+		case rok && r == 0:
+			return exp.Left
+		case lok && l == 0:
+			return exp.Right
+		case rok && r == -1 && pure(exp.Left), lok && l == -1 && pure(exp.Right):
+			return constant(-1, exp.Span)
+		}
+	}
+	return exp
+}
+
+// evaluate applies op to two 16-bit values, division by zero is left to Math.divide at runtime
+func evaluate(op token.TokenType, l int, r int) (int, bool) {
+	switch op {
+	case token.PLUS:
+		return l + r, true
+	case token.MINUS:
+		return l - r, true
+	case token.ASTERISK:
+		return l * r, true
+	case token.FSLASH:
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	case token.AMP:
+		return l & r, true
+	case token.BAR:
+		return l | r, true
+	case token.LT:
+		return boolean(l < r), true
+	case token.GT:
+		return boolean(l > r), true
+	case token.ASSIGN:
+		return boolean(l == r), true
+	}
+	return 0, false
+}
+
+func Value(exp parseTree.Expression) (int, bool) {
+	switch exp := exp.(type) {
+	case *parseTree.IntegerConstant:
+		return exp.Value, true
+	case *parseTree.KeywordConstant:
+		switch exp.Token.Type {
+		case token.TRUE:
+			return -1, true
+		case token.FALSE:
+			return 0, true
+		}
+	case *parseTree.Group:
+		return Value(exp.Expression)
+	case *parseTree.Prefix:
+		v, ok := Value(exp.Expression)
+		if !ok {
+			return 0, false
+		}
+		if exp.Operator.Type == token.MINUS {
+			return int(int16(-v)), true
+		}
+		return int(int16(^v)), true
+	}
+	return 0, false
+}
+
+// constant builds the cheapest expression for v truncated to 16 bits, negative values need a neg or not
+func constant(v int, span token.Span) parseTree.Expression {
+	v = int(int16(v))
+	switch {
+	case v >= 0:
+		return integer(v, span)
+	case v == -32768:
+		return &parseTree.Prefix{Span: span, Operator: token.Token{Type: token.NOT, Literal: token.NOT}, Expression: integer(32767, span)}
+	}
+	return &parseTree.Prefix{Span: span, Operator: token.Token{Type: token.MINUS, Literal: token.MINUS}, Expression: integer(-v, span)}
+}
+
+func integer(v int, span token.Span) *parseTree.IntegerConstant {
+	literal := strconv.Itoa(v)
+	return &parseTree.IntegerConstant{
+		Span:  span,
+		Token: token.Token{Type: token.INT_CONST, Literal: literal, Raw: literal, Span: span},
+		Value: v,
+	}
+}
+
+func boolean(b bool) int {
+	if b {
+		return -1
+	}
+	return 0
+}
+
+// pure reports whether dropping exp cannot skip a call or a runtime error
+func pure(exp parseTree.Expression) bool {
+	switch exp := exp.(type) {
+	case *parseTree.IntegerConstant, *parseTree.KeywordConstant:
+		return true
+	case *parseTree.Identifier:
+		return exp.Indexer == nil || pure(exp.Indexer)
+	case *parseTree.Group:
+		return pure(exp.Expression)
+	case *parseTree.Prefix:
+		return pure(exp.Expression)
+	case *parseTree.Infix:
+		return exp.Operator.Type != token.ASTERISK && exp.Operator.Type != token.FSLASH && pure(exp.Left) && pure(exp.Right)
+	}
+	return false
+}
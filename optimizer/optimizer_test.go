@@ -0,0 +1,61 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3", "6"},
+		{"(1 + 2) * (10 - 4) / 3", "6"},
+		{"-5", "(-5)"},
+		{"-(-5)", "5"},
+		{"~0", "(-1)"},
+		{"~true", "0"},
+		{"32767 + 1", "(~32767)"},
+		{"300 * 300", "24464"},
+		{"-7 / 2", "(-3)"},
+		{"1 / 0", "(1 / 0)"},
+		{"(3 < 5) & (2 = 2)", "(-1)"},
+		{"x + 0", "x"},
+		{"0 + x", "x"},
+		{"x - 0", "x"},
+		{"0 - x", "(-x)"},
+		{"x * 1", "x"},
+		{"1 * x", "x"},
+		{"x * 0", "0"},
+		{"0 * a[x]", "0"},
+		{"Main.f(x) * 0", "(Main.f(x) * 0)"},
+		{"x / 1", "x"},
+		{"~~x", "x"},
+		{"~(~x)", "x"},
+		{"-(-x)", "x"},
+		{"x & true", "x"},
+		{"x | false", "x"},
+		{"x & 0", "0"},
+		{"a[2 * 2] + (x * (3 - 2))", "(a[4] + x)"},
+		{"Main.f(1 + 1, x + (2 - 2))", "Main.f(2, x)"},
+	}
+
+	for _, test := range tests {
+		input := "class Main { function int f(int x) { var Array a; return " + test.input + "; } }"
+		class, diags := parser.New(tokenizer.New(input)).ParseClass()
+		if len(diags) != 0 {
+			t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+		}
+
+		Optimize(class)
+
+		ret := class.Subroutines()[0].SubroutineBody.Statements[0].(*parseTree.ReturnStatement)
+		if ret.Expression.String() != test.expected {
+			t.Fatalf("Optimize(%s) expected: %s, received: %s", test.input, test.expected, ret.Expression.String())
+		}
+	}
+}
@@ -2,19 +2,67 @@ package parseTree
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/tivt2/jack-compiler/token"
 )
 
 type Node interface {
 	String() string
+	Range() token.Span
 }
 
 type Class struct {
+	token.Span
 	Token          token.Token
 	Ident          *Identifier
-	ClassVarDecs   []*ClassVarDec
-	SubroutineDecs []*SubroutineDec
+	ClassVarDecs   []Declaration
+	SubroutineDecs []Declaration
+	Comments       []token.Trivia
+}
+
+func (c *Class) ClassVars() []*ClassVarDec {
+	var out []*ClassVarDec
+	for _, dec := range c.ClassVarDecs {
+		if cvd, ok := dec.(*ClassVarDec); ok {
+			out = append(out, cvd)
+		}
+	}
+	return out
+}
+
+func (c *Class) Subroutines() []*SubroutineDec {
+	var out []*SubroutineDec
+	for _, dec := range c.SubroutineDecs {
+		if sd, ok := dec.(*SubroutineDec); ok {
+			out = append(out, sd)
+		}
+	}
+	return out
+}
+
+// Annotations returns the text of comments on line, or on their own line just before it
+func (c *Class) Annotations(line int) []string {
+	var out []string
+	for _, comment := range c.Comments {
+		if comment.Start.Line != line && (comment.Trailing || comment.End.Line != line-1) {
+			continue
+		}
+		text := strings.TrimPrefix(comment.Text, "//")
+		text = strings.TrimPrefix(text, "/*")
+		text = strings.TrimSuffix(text, "*/")
+		out = append(out, strings.TrimSpace(text))
+	}
+	return out
+}
+
+func (c *Class) Annotated(line int, annotation string) bool {
+	for _, text := range c.Annotations(line) {
+		if strings.HasPrefix(text, annotation) {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Class) String() string {
@@ -34,12 +82,20 @@ func (c *Class) String() string {
 	return out.String()
 }
 
+type Declaration interface {
+	Node
+	decNode()
+}
+
 type ClassVarDec struct {
-	Kind    token.Token
-	DecType token.Token
-	Ident   *Identifier
+	token.Span
+	Kind      token.Token
+	DecType   token.Token
+	Ident     *Identifier
+	Continued bool // declared after a comma, sharing Kind and DecType with the previous dec
 }
 
+func (cvd *ClassVarDec) decNode() {}
 func (cvd *ClassVarDec) String() string {
 	var out bytes.Buffer
 
@@ -52,6 +108,7 @@ func (cvd *ClassVarDec) String() string {
 }
 
 type SubroutineDec struct {
+	token.Span
 	Kind           token.Token
 	DecType        token.Token
 	Ident          *Identifier
@@ -59,6 +116,7 @@ type SubroutineDec struct {
 	SubroutineBody *SubroutineBody
 }
 
+func (sd *SubroutineDec) decNode() {}
 func (sd *SubroutineDec) String() string {
 	var out bytes.Buffer
 
@@ -81,6 +139,7 @@ func (sd *SubroutineDec) String() string {
 }
 
 type Param struct {
+	token.Span
 	DecType token.Token
 	Ident   *Identifier
 }
@@ -88,10 +147,21 @@ type Param struct {
 func (p *Param) String() string { return p.DecType.Literal + " " + p.Ident.String() }
 
 type SubroutineBody struct {
-	VarDecs    []*VarDec
+	token.Span
+	VarDecs    []Declaration
 	Statements []Statement
 }
 
+func (sb *SubroutineBody) Vars() []*VarDec {
+	var out []*VarDec
+	for _, dec := range sb.VarDecs {
+		if vd, ok := dec.(*VarDec); ok {
+			out = append(out, vd)
+		}
+	}
+	return out
+}
+
 func (sb *SubroutineBody) String() string {
 	var out bytes.Buffer
 
@@ -106,11 +176,14 @@ func (sb *SubroutineBody) String() string {
 }
 
 type VarDec struct {
-	Kind    token.Token
-	DecType token.Token
-	Ident   *Identifier
+	token.Span
+	Kind      token.Token
+	DecType   token.Token
+	Ident     *Identifier
+	Continued bool
 }
 
+func (vd *VarDec) decNode() {}
 func (vd *VarDec) String() string {
 	var out bytes.Buffer
 
@@ -122,6 +195,13 @@ func (vd *VarDec) String() string {
 	return out.String()
 }
 
+type BadDeclaration struct {
+	token.Span
+}
+
+func (bd *BadDeclaration) decNode()       {}
+func (bd *BadDeclaration) String() string { return "<bad declaration>" }
+
 // STATEMENTS HERE
 
 type Statement interface {
@@ -129,7 +209,15 @@ type Statement interface {
 	stmtNode()
 }
 
+type BadStatement struct {
+	token.Span
+}
+
+func (bs *BadStatement) stmtNode()      {}
+func (bs *BadStatement) String() string { return "<bad statement>" }
+
 type LetStatement struct {
+	token.Span
 	Token      token.Token
 	Ident      *Identifier
 	Expression Expression
@@ -149,6 +237,7 @@ func (ls *LetStatement) String() string {
 }
 
 type ReturnStatement struct {
+	token.Span
 	Token      token.Token
 	Expression Expression
 }
@@ -167,6 +256,7 @@ func (rs *ReturnStatement) String() string {
 }
 
 type DoStatement struct {
+	token.Span
 	Token      token.Token
 	Expression Expression
 }
@@ -183,9 +273,11 @@ func (ds *DoStatement) String() string {
 }
 
 type IfStatement struct {
+	token.Span
 	Token      token.Token
 	Expression Expression
 	IfStmts    []Statement
+	ElseToken  token.Token
 	Else       []Statement
 }
 
@@ -211,6 +303,7 @@ func (is *IfStatement) String() string {
 }
 
 type WhileStatement struct {
+	token.Span
 	Token      token.Token
 	Expression Expression
 	Stmts      []Statement
@@ -239,6 +332,7 @@ type Expression interface {
 }
 
 type Prefix struct {
+	token.Span
 	Operator   token.Token
 	Expression Expression
 }
@@ -257,6 +351,7 @@ func (p *Prefix) String() string {
 }
 
 type Infix struct {
+	token.Span
 	Operator token.Token
 	Left     Expression
 	Right    Expression
@@ -275,7 +370,16 @@ func (i *Infix) String() string {
 	return out.String()
 }
 
+type Group struct {
+	token.Span
+	Expression Expression
+}
+
+func (g *Group) expNode()       {}
+func (g *Group) String() string { return g.Expression.String() }
+
 type IntegerConstant struct {
+	token.Span
 	Token token.Token
 	Value int
 }
@@ -284,6 +388,7 @@ func (ic *IntegerConstant) expNode()       {}
 func (ic *IntegerConstant) String() string { return ic.Token.Literal }
 
 type KeywordConstant struct {
+	token.Span
 	Token token.Token
 	Value string
 }
@@ -292,6 +397,7 @@ func (kc *KeywordConstant) expNode()       {}
 func (kc *KeywordConstant) String() string { return kc.Token.Literal }
 
 type StringConstant struct {
+	token.Span
 	Token token.Token
 	Value string
 }
@@ -300,6 +406,7 @@ func (sc *StringConstant) expNode()       {}
 func (sc *StringConstant) String() string { return `"` + sc.Token.Literal + `"` }
 
 type Identifier struct {
+	token.Span
 	Token   token.Token
 	Value   string
 	Indexer Expression
@@ -322,6 +429,7 @@ func (i *Identifier) String() string {
 }
 
 type SubroutineCall struct {
+	token.Span
 	Ident      *Identifier
 	Subroutine *Identifier
 	ExpList    []Expression
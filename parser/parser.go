@@ -1,9 +1,10 @@
 package parser
 
 import (
-	"log"
+	"sort"
 	"strconv"
 
+	"github.com/tivt2/jack-compiler/diagnostic"
 	"github.com/tivt2/jack-compiler/parseTree"
 	"github.com/tivt2/jack-compiler/token"
 	"github.com/tivt2/jack-compiler/tokenizer"
@@ -12,8 +13,57 @@ import (
 type Parser struct {
 	tkzr *tokenizer.Tokenizer
 
+	prevToken token.Token
 	curToken  token.Token
 	peekToken token.Token
+	pending   []token.Token
+
+	diagnostics []diagnostic.Diagnostic
+}
+
+type bailout struct{}
+
+type syncSet struct {
+	semicolon bool
+	stops     map[token.TokenType]bool
+}
+
+var statementSync = syncSet{
+	semicolon: true,
+	stops: map[token.TokenType]bool{
+		token.RBRACE:      true,
+		token.VAR:         true,
+		token.LET:         true,
+		token.DO:          true,
+		token.IF:          true,
+		token.WHILE:       true,
+		token.RETURN:      true,
+		token.CONSTRUCTOR: true,
+		token.FUNCTION:    true,
+		token.METHOD:      true,
+		token.EOF:         true,
+	},
+}
+
+var classVarDecSync = syncSet{
+	semicolon: true,
+	stops: map[token.TokenType]bool{
+		token.FIELD:       true,
+		token.STATIC:      true,
+		token.CONSTRUCTOR: true,
+		token.FUNCTION:    true,
+		token.METHOD:      true,
+		token.EOF:         true,
+	},
+}
+
+var subroutineDecSync = syncSet{
+	stops: map[token.TokenType]bool{
+		token.CONSTRUCTOR: true,
+		token.FUNCTION:    true,
+		token.METHOD:      true,
+		token.EOF:         true,
+	},
 }
 
 func New(tkzr *tokenizer.Tokenizer) *Parser {
@@ -26,8 +76,23 @@ func New(tkzr *tokenizer.Tokenizer) *Parser {
 }
 
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
+	if len(p.pending) > 0 {
+		p.peekToken = p.pending[0]
+		p.pending = p.pending[1:]
+		return
+	}
 	p.peekToken = p.tkzr.Advance()
+	for p.peekToken.Type == token.ILLEGAL {
+		p.peekToken = p.tkzr.Advance()
+	}
+}
+
+func (p *Parser) backup() {
+	p.pending = append([]token.Token{p.peekToken}, p.pending...)
+	p.peekToken = p.curToken
+	p.curToken = p.prevToken
 }
 
 func (p *Parser) expectToken(tokenType token.TokenType) bool {
@@ -48,36 +113,131 @@ func (p *Parser) expectPeek(tokenType token.TokenType) bool {
 	}
 }
 
-func (p *Parser) ParseClass() *parseTree.Class {
-	class := &parseTree.Class{Token: p.curToken}
+func (p *Parser) fail(msg string, expected string, got token.Token) {
+	if n := len(p.diagnostics); n == 0 || p.diagnostics[n-1].Start.Offset < got.Start.Offset {
+		p.diagnostics = append(p.diagnostics, diagnostic.Diagnostic{
+			Severity: diagnostic.ERROR,
+			Message:  msg,
+			Expected: expected,
+			Got:      got.Describe(),
+			Span:     got.Span,
+		})
+	}
+	panic(bailout{})
+}
+
+func (p *Parser) recoverWith(start token.Token, set syncSet, bad func(span token.Span)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if _, ok := r.(bailout); !ok {
+		panic(r)
+	}
+	p.sync(start, set)
+	bad(spanOf(start, p.curToken))
+}
+
+func (p *Parser) sync(start token.Token, set syncSet) {
+	progressed := func() bool { return p.curToken.Start.Offset > start.Start.Offset }
+	closesClass := func() bool { return p.curToken.Type == token.RBRACE && p.peekToken.Type == token.EOF }
+
+	if (set.stops[p.curToken.Type] || closesClass()) && progressed() {
+		p.backup()
+		return
+	}
+	depth := 0
+	if p.curToken.Type == token.LBRACE {
+		depth++
+	}
+	for p.peekToken.Type != token.EOF && !isSubroutineKind(p.peekToken.Type) {
+		if depth == 0 && (set.stops[p.peekToken.Type] || set.semicolon && p.curToken.Type == token.SEMICOLON) {
+			return
+		}
+		p.nextToken()
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+	if closesClass() && progressed() {
+		p.backup()
+	}
+}
+
+func isSubroutineKind(tokenType token.TokenType) bool {
+	return tokenType == token.CONSTRUCTOR || tokenType == token.FUNCTION || tokenType == token.METHOD
+}
+
+func newIdentifier(tk token.Token) *parseTree.Identifier {
+	return &parseTree.Identifier{Span: tk.Span, Token: tk, Value: tk.Literal}
+}
+
+func spanOf(start token.Token, end token.Token) token.Span {
+	return token.Span{Start: start.Start, End: end.End}
+}
+
+func (p *Parser) ParseClass() (class *parseTree.Class, diags []diagnostic.Diagnostic) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+		}
+		diags = p.allDiagnostics()
+	}()
+
+	class = &parseTree.Class{Token: p.curToken}
 	if !p.expectToken(token.CLASS) {
-		log.Fatalf("Invalid class keyword, received: %v", p.curToken)
+		p.fail("Invalid class keyword", "'class'", p.curToken)
 	}
-	class.Ident = &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	class.Ident = newIdentifier(p.curToken)
 	if !p.expectToken(token.IDENT) {
-		log.Fatalf("Invalid class identifier, received: %v", p.curToken)
+		p.fail("Invalid class identifier", "class name", p.curToken)
 	}
 	if !p.expectToken(token.LBRACE) {
-		log.Fatalf("Invalid class, missing {, received: %v", p.curToken)
+		p.fail("Invalid class, missing {", "'{'", p.curToken)
 	}
 	for p.curToken.Type == token.FIELD || p.curToken.Type == token.STATIC {
 		class.ClassVarDecs = p.parseClassVarDec(class.ClassVarDecs)
 		p.nextToken()
 	}
 
-	for p.curToken.Type != token.RBRACE {
+	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF {
 		class.SubroutineDecs = append(class.SubroutineDecs, p.parseSubroutineDec())
 		p.nextToken()
 	}
+	class.Comments = p.tkzr.Comments()
+	if p.curToken.Type != token.RBRACE {
+		p.fail("Invalid class, missing }", "'}'", p.curToken)
+	}
+	class.Span = spanOf(class.Token, p.curToken)
 	p.nextToken()
 
 	if !p.expectToken(token.EOF) {
-		log.Fatalf("Invalid class, aditional text after class closing brace")
+		p.fail("Invalid class, aditional text after class closing brace", "end of file", p.curToken)
 	}
-	return class
+	return class, p.allDiagnostics()
+}
+
+func (p *Parser) allDiagnostics() []diagnostic.Diagnostic {
+	diags := append([]diagnostic.Diagnostic{}, p.tkzr.Diagnostics()...)
+	diags = append(diags, p.diagnostics...)
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Start.Offset < diags[j].Start.Offset
+	})
+	return diags
 }
 
-func (p *Parser) parseClassVarDec(cvds []*parseTree.ClassVarDec) []*parseTree.ClassVarDec {
+func (p *Parser) parseClassVarDec(cvds []parseTree.Declaration) (out []parseTree.Declaration) {
+	defer p.recoverWith(p.curToken, classVarDecSync, func(span token.Span) {
+		out = append(cvds, &parseTree.BadDeclaration{Span: span})
+	})
+
 	cvd := &parseTree.ClassVarDec{Kind: p.curToken}
 	p.nextToken()
 
@@ -87,31 +247,44 @@ func (p *Parser) parseClassVarDec(cvds []*parseTree.ClassVarDec) []*parseTree.Cl
 	case token.INT, token.CHAR, token.BOOLEAN:
 		cvd.DecType = p.curToken
 	default:
-		log.Fatalf("Invalid class var dec type, received: %v", p.curToken)
+		p.fail("Invalid class var dec type", "type", p.curToken)
 	}
 
 	if !p.expectPeek(token.IDENT) {
-		log.Fatalf("Invalid class var dec identifier, received: %v", p.peekToken)
+		p.fail("Invalid class var dec identifier", "identifier", p.peekToken)
 	}
-	cvd.Ident = &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	cvds = append(cvds, cvd)
+	cvd.Ident = newIdentifier(p.curToken)
+	decs := []*parseTree.ClassVarDec{cvd}
 	p.nextToken()
 	for p.curToken.Type == token.COMMA {
-		p.nextToken()
-		newCvd := &parseTree.ClassVarDec{
-			Kind:    cvd.Kind,
-			DecType: cvd.DecType,
-			Ident:   &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		if !p.expectPeek(token.IDENT) {
+			p.fail("Invalid class var dec identifier", "identifier", p.peekToken)
 		}
-		cvds = append(cvds, newCvd)
-		p.expectPeek(token.COMMA)
-		p.expectPeek(token.SEMICOLON)
+		decs = append(decs, &parseTree.ClassVarDec{
+			Kind:      cvd.Kind,
+			DecType:   cvd.DecType,
+			Ident:     newIdentifier(p.curToken),
+			Continued: true,
+		})
+		p.nextToken()
+	}
+	if p.curToken.Type != token.SEMICOLON {
+		p.fail("Invalid class var dec, missing semicolon", "';'", p.curToken)
+	}
+	out = cvds
+	for _, dec := range decs {
+		dec.Span = spanOf(cvd.Kind, p.curToken)
+		out = append(out, dec)
 	}
 
-	return cvds
+	return out
 }
 
-func (p *Parser) parseSubroutineDec() *parseTree.SubroutineDec {
+func (p *Parser) parseSubroutineDec() (dec parseTree.Declaration) {
+	defer p.recoverWith(p.curToken, subroutineDecSync, func(span token.Span) {
+		dec = &parseTree.BadDeclaration{Span: span}
+	})
+
 	sd := &parseTree.SubroutineDec{}
 
 	switch p.curToken.Type {
@@ -122,7 +295,7 @@ func (p *Parser) parseSubroutineDec() *parseTree.SubroutineDec {
 	case token.FUNCTION:
 		sd.Kind = p.curToken
 	default:
-		log.Fatalf("Invalid sub dec, missing kind, received: %v", p.curToken)
+		p.fail("Invalid sub dec, missing kind", "'constructor', 'function' or 'method'", p.curToken)
 	}
 	p.nextToken()
 
@@ -132,33 +305,41 @@ func (p *Parser) parseSubroutineDec() *parseTree.SubroutineDec {
 	case token.INT, token.CHAR, token.VOID, token.BOOLEAN:
 		sd.DecType = p.curToken
 	default:
-		log.Fatalf("Invalid var dec type, received: %v", p.curToken)
+		p.fail("Invalid sub dec type", "type", p.curToken)
 	}
 
 	if !p.expectPeek(token.IDENT) {
-		log.Fatalf("Invalid var dec identifier, received: %v", p.peekToken)
+		p.fail("Invalid sub dec identifier", "identifier", p.peekToken)
 	}
-	sd.Ident = &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	sd.Ident = newIdentifier(p.curToken)
 	if !p.expectPeek(token.LPAREN) {
-		log.Fatalf("Invalid sub dec, missing (, received: %v", p.peekToken)
+		p.fail("Invalid sub dec, missing (", "'('", p.peekToken)
 	}
 	p.nextToken()
 
-	for p.curToken.Type != token.RPAREN {
-		p.expectToken(token.COMMA)
+	for p.curToken.Type != token.RPAREN && p.curToken.Type != token.EOF {
+		if len(sd.Params) > 0 && !p.expectToken(token.COMMA) {
+			p.fail("Invalid sub dec, missing , between parameters", "',' or ')'", p.curToken)
+		}
 		sd.Params = append(sd.Params, p.parseParam())
 		p.nextToken()
 	}
+	if p.curToken.Type != token.RPAREN {
+		p.fail("Invalid sub dec, missing )", "')'", p.curToken)
+	}
 	p.nextToken()
 
+	lbrace := p.curToken
 	if !p.expectToken(token.LBRACE) {
-		log.Fatalf("Invalid sub dec, missing {, received: %v", p.curToken)
+		p.fail("Invalid sub dec, missing {", "'{'", p.curToken)
 	}
 
 	sd.SubroutineBody = p.parseSubroutineBody()
 	if p.curToken.Type != token.RBRACE {
-		log.Fatalf("Invalid sub dec, missing }, received: %v", p.curToken)
+		p.fail("Invalid sub dec, missing }", "'}'", p.curToken)
 	}
+	sd.SubroutineBody.Span = spanOf(lbrace, p.curToken)
+	sd.Span = spanOf(sd.Kind, p.curToken)
 
 	return sd
 }
@@ -171,13 +352,14 @@ func (p *Parser) parseParam() *parseTree.Param {
 	case token.INT, token.CHAR, token.BOOLEAN:
 		param.DecType = p.curToken
 	default:
-		log.Fatalf("Invalid param dec type, received: %v", p.curToken)
+		p.fail("Invalid param dec type", "type", p.curToken)
 	}
 
 	if !p.expectPeek(token.IDENT) {
-		log.Fatalf("Invalid var dec identifier, received: %v", p.peekToken)
+		p.fail("Invalid param dec identifier", "identifier", p.peekToken)
 	}
-	param.Ident = &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	param.Ident = newIdentifier(p.curToken)
+	param.Span = spanOf(param.DecType, p.curToken)
 
 	return param
 }
@@ -192,13 +374,17 @@ func (p *Parser) parseSubroutineBody() *parseTree.SubroutineBody {
 
 	sb.Statements = p.parseStatements()
 	if p.curToken.Type != token.RBRACE {
-		log.Fatalf("Invalid sub body statements, missing }, received: %v", p.peekToken)
+		p.fail("Invalid sub body statements, missing }", "'}'", p.curToken)
 	}
 
 	return sb
 }
 
-func (p *Parser) parseVarDec(vds []*parseTree.VarDec) []*parseTree.VarDec {
+func (p *Parser) parseVarDec(vds []parseTree.Declaration) (out []parseTree.Declaration) {
+	defer p.recoverWith(p.curToken, statementSync, func(span token.Span) {
+		out = append(vds, &parseTree.BadDeclaration{Span: span})
+	})
+
 	vd := &parseTree.VarDec{Kind: p.curToken}
 	p.nextToken()
 
@@ -208,33 +394,42 @@ func (p *Parser) parseVarDec(vds []*parseTree.VarDec) []*parseTree.VarDec {
 	case token.INT, token.CHAR, token.BOOLEAN:
 		vd.DecType = p.curToken
 	default:
-		log.Fatalf("Invalid var dec type, received: %v", p.curToken)
+		p.fail("Invalid var dec type", "type", p.curToken)
 	}
 	if !p.expectPeek(token.IDENT) {
-		log.Fatalf("Invalid var dec identifier, received: %v", p.peekToken)
+		p.fail("Invalid var dec identifier", "identifier", p.peekToken)
 	}
-	vd.Ident = &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	vds = append(vds, vd)
+	vd.Ident = newIdentifier(p.curToken)
+	decs := []*parseTree.VarDec{vd}
 	p.nextToken()
 	for p.curToken.Type == token.COMMA {
-		p.nextToken()
-		newVd := &parseTree.VarDec{
-			Kind:    vd.Kind,
-			DecType: vd.DecType,
-			Ident:   &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		if !p.expectPeek(token.IDENT) {
+			p.fail("Invalid var dec identifier", "identifier", p.peekToken)
 		}
-		vds = append(vds, newVd)
-		p.expectPeek(token.COMMA)
-		p.expectPeek(token.SEMICOLON)
+		decs = append(decs, &parseTree.VarDec{
+			Kind:      vd.Kind,
+			DecType:   vd.DecType,
+			Ident:     newIdentifier(p.curToken),
+			Continued: true,
+		})
+		p.nextToken()
+	}
+	if p.curToken.Type != token.SEMICOLON {
+		p.fail("Invalid var dec, missing semicolon", "';'", p.curToken)
+	}
+	out = vds
+	for _, dec := range decs {
+		dec.Span = spanOf(vd.Kind, p.curToken)
+		out = append(out, dec)
 	}
 
-	return vds
+	return out
 }
 
 func (p *Parser) parseStatements() []parseTree.Statement {
 	var stmts []parseTree.Statement
 
-	for p.curToken.Type != token.RBRACE {
+	for p.curToken.Type != token.RBRACE && p.curToken.Type != token.EOF && !isSubroutineKind(p.curToken.Type) {
 		stmts = append(stmts, p.parseStatement())
 		p.nextToken()
 	}
@@ -242,7 +437,11 @@ func (p *Parser) parseStatements() []parseTree.Statement {
 	return stmts
 }
 
-func (p *Parser) parseStatement() parseTree.Statement {
+func (p *Parser) parseStatement() (stmt parseTree.Statement) {
+	defer p.recoverWith(p.curToken, statementSync, func(span token.Span) {
+		stmt = &parseTree.BadStatement{Span: span}
+	})
+
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
@@ -255,6 +454,7 @@ func (p *Parser) parseStatement() parseTree.Statement {
 	case token.WHILE:
 		return p.parseWhileStatement()
 	default:
+		p.fail("Invalid statement", "statement", p.curToken)
 		return nil
 	}
 }
@@ -262,25 +462,29 @@ func (p *Parser) parseStatement() parseTree.Statement {
 func (p *Parser) parseLetStatement() *parseTree.LetStatement {
 	ls := &parseTree.LetStatement{Token: p.curToken}
 	if !p.expectPeek(token.IDENT) {
-		log.Fatalf("Invalid let statement, missing ident, received: %v", p.curToken)
+		p.fail("Invalid let statement, missing ident", "identifier", p.peekToken)
 	}
 
-	ls.Ident = &parseTree.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ls.Ident = newIdentifier(p.curToken)
 	if p.expectPeek(token.LBRACKET) {
 		p.nextToken()
 		ls.Ident.Indexer = p.parseExpression()
-		p.nextToken()
+		if !p.expectPeek(token.RBRACKET) {
+			p.fail("Invalid let statement, missing ]", "']'", p.peekToken)
+		}
+		ls.Ident.Span = spanOf(ls.Ident.Token, p.curToken)
 	}
 
 	if !p.expectPeek(token.ASSIGN) {
-		log.Fatalf("Invalid let statement, missing assign, received: %v", p.curToken)
+		p.fail("Invalid let statement, missing assign", "'='", p.peekToken)
 	}
 
 	p.nextToken()
 	ls.Expression = p.parseExpression()
 	if !p.expectPeek(token.SEMICOLON) {
-		log.Fatalf("Invalid let statement, missing semicolon, received: %v", p.curToken)
+		p.fail("Invalid let statement, missing semicolon", "';'", p.peekToken)
 	}
+	ls.Span = spanOf(ls.Token, p.curToken)
 
 	return ls
 }
@@ -290,12 +494,14 @@ func (p *Parser) parseReturnStatement() *parseTree.ReturnStatement {
 
 	p.nextToken()
 	if p.curToken.Type == token.SEMICOLON {
+		rs.Span = spanOf(rs.Token, p.curToken)
 		return rs
 	}
 	rs.Expression = p.parseExpression()
 	if !p.expectPeek(token.SEMICOLON) {
-		log.Fatalf("Invalid return statement, missing semicolon, received: %v", p.curToken)
+		p.fail("Invalid return statement, missing semicolon", "';'", p.peekToken)
 	}
+	rs.Span = spanOf(rs.Token, p.curToken)
 
 	return rs
 }
@@ -306,8 +512,9 @@ func (p *Parser) parseDoStatement() *parseTree.DoStatement {
 	p.nextToken()
 	ds.Expression = p.parseExpression()
 	if !p.expectPeek(token.SEMICOLON) {
-		log.Fatalf("Invalid do statement, missing semicolon, received: %v", p.curToken)
+		p.fail("Invalid do statement, missing semicolon", "';'", p.peekToken)
 	}
+	ds.Span = spanOf(ds.Token, p.curToken)
 
 	return ds
 }
@@ -316,35 +523,37 @@ func (p *Parser) parseIfStatement() *parseTree.IfStatement {
 	is := &parseTree.IfStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		log.Fatalf("Invalid if statement, missing (, received: %v", p.curToken)
+		p.fail("Invalid if statement, missing (", "'('", p.peekToken)
 	}
 
 	p.nextToken()
 	is.Expression = p.parseExpression()
 	if !p.expectPeek(token.RPAREN) {
-		log.Fatalf("Invalid if statement, missing ), received: %v", p.curToken)
+		p.fail("Invalid if statement, missing )", "')'", p.peekToken)
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		log.Fatalf("Invalid if statement, missing {, received: %v", p.curToken)
+		p.fail("Invalid if statement, missing {", "'{'", p.peekToken)
 	}
 
 	p.nextToken()
 	is.IfStmts = p.parseStatements()
 	if p.curToken.Type != token.RBRACE {
-		log.Fatalf("Invalid if statement, missing }, received: %v", p.curToken)
+		p.fail("Invalid if statement, missing }", "'}'", p.curToken)
 	}
 
 	if p.expectPeek(token.ELSE) {
+		is.ElseToken = p.curToken
 		if !p.expectPeek(token.LBRACE) {
-			log.Fatalf("Invalid else statement, missing {, received: %v", p.curToken)
+			p.fail("Invalid else statement, missing {", "'{'", p.peekToken)
 		}
 		p.nextToken()
 		is.Else = p.parseStatements()
 		if p.curToken.Type != token.RBRACE {
-			log.Fatalf("Invalid else statement, missing }, received: %v", p.curToken)
+			p.fail("Invalid else statement, missing }", "'}'", p.curToken)
 		}
 	}
+	is.Span = spanOf(is.Token, p.curToken)
 
 	return is
 }
@@ -353,24 +562,25 @@ func (p *Parser) parseWhileStatement() *parseTree.WhileStatement {
 	is := &parseTree.WhileStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		log.Fatalf("Invalid while statement, missing (, received: %v", p.curToken)
+		p.fail("Invalid while statement, missing (", "'('", p.peekToken)
 	}
 
 	p.nextToken()
 	is.Expression = p.parseExpression()
 	if !p.expectPeek(token.RPAREN) {
-		log.Fatalf("Invalid while statement, missing ), received: %v", p.curToken)
+		p.fail("Invalid while statement, missing )", "')'", p.peekToken)
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		log.Fatalf("Invalid while statement, missing {, received: %v", p.curToken)
+		p.fail("Invalid while statement, missing {", "'{'", p.peekToken)
 	}
 
 	p.nextToken()
 	is.Stmts = p.parseStatements()
 	if p.curToken.Type != token.RBRACE {
-		log.Fatalf("Invalid while statement, missing }, received: %v", p.curToken)
+		p.fail("Invalid while statement, missing }", "'}'", p.curToken)
 	}
+	is.Span = spanOf(is.Token, p.curToken)
 
 	return is
 }
@@ -384,7 +594,12 @@ func (p *Parser) parseExpression() parseTree.Expression {
 			op := p.curToken
 			p.nextToken()
 			exp2 := p.parseTerm()
-			exp = &parseTree.Infix{Operator: op, Left: exp, Right: exp2}
+			exp = &parseTree.Infix{
+				Span:     token.Span{Start: exp.Range().Start, End: exp2.Range().End},
+				Operator: op,
+				Left:     exp,
+				Right:    exp2,
+			}
 		default:
 			return exp
 		}
@@ -395,19 +610,20 @@ func (p *Parser) parseTerm() parseTree.Expression {
 	switch p.curToken.Type {
 	case token.MINUS, token.NOT:
 		return p.parsePrefix()
-	case token.INT:
+	case token.INT_CONST:
 		return p.parseIntegerConstant()
-	case token.QUOT:
+	case token.STRING_CONST:
 		return p.parseStringConstant()
 	case token.TRUE, token.FALSE, token.NULL, token.THIS:
 		return p.parseKeywordConstant()
 	case token.LPAREN:
+		lparen := p.curToken
 		p.nextToken()
 		exp := p.parseExpression()
 		if !p.expectPeek(token.RPAREN) {
-			log.Fatalf("Invalid group expression, missing ), received: %v", p.curToken)
+			p.fail("Invalid group expression, missing )", "')'", p.peekToken)
 		}
-		return exp
+		return &parseTree.Group{Span: spanOf(lparen, p.curToken), Expression: exp}
 	case token.IDENT:
 		initIdent := p.curToken
 
@@ -419,9 +635,10 @@ func (p *Parser) parseTerm() parseTree.Expression {
 			exp := p.parseExpression()
 
 			if !p.expectPeek(token.RBRACKET) {
-				log.Fatalf("Invalid index expression, missing ], received: %v", p.curToken)
+				p.fail("Invalid index expression, missing ]", "']'", p.peekToken)
 			}
 			return &parseTree.Identifier{
+				Span:    spanOf(initIdent, p.curToken),
 				Token:   initIdent,
 				Value:   initIdent.Literal,
 				Indexer: exp,
@@ -429,25 +646,29 @@ func (p *Parser) parseTerm() parseTree.Expression {
 		case token.DOT:
 			p.nextToken()
 			if !p.expectPeek(token.IDENT) {
-				log.Fatalf("Invalid dot call, missing 2nd ident, received: %v", p.curToken)
+				p.fail("Invalid dot call, missing 2nd ident", "identifier", p.peekToken)
 			}
 			secondIdent := p.curToken
-			return &parseTree.SubroutineCall{
-				Ident:      &parseTree.Identifier{Token: initIdent, Value: initIdent.Literal, Indexer: nil},
-				Subroutine: &parseTree.Identifier{Token: secondIdent, Value: secondIdent.Literal, Indexer: nil},
+			call := &parseTree.SubroutineCall{
+				Ident:      newIdentifier(initIdent),
+				Subroutine: newIdentifier(secondIdent),
 				ExpList:    p.parseExpressionList(),
 			}
+			call.Span = spanOf(initIdent, p.curToken)
+			return call
 		case token.LPAREN:
-			return &parseTree.SubroutineCall{
+			call := &parseTree.SubroutineCall{
 				Ident:      nil,
-				Subroutine: &parseTree.Identifier{Token: initIdent, Value: initIdent.Literal, Indexer: nil},
+				Subroutine: newIdentifier(initIdent),
 				ExpList:    p.parseExpressionList(),
 			}
+			call.Span = spanOf(initIdent, p.curToken)
+			return call
 		default:
-			return &parseTree.Identifier{Token: initIdent, Value: initIdent.Literal, Indexer: nil}
+			return newIdentifier(initIdent)
 		}
 	default:
-		log.Fatalf("Invalid term received: %v", p.curToken)
+		p.fail("Invalid term", "term", p.curToken)
 		return nil
 	}
 }
@@ -458,11 +679,16 @@ func (p *Parser) parseExpressionList() []parseTree.Expression {
 	}
 	p.nextToken()
 	list := []parseTree.Expression{}
-	for p.curToken.Type != token.RPAREN {
-		p.expectToken(token.COMMA)
+	for p.curToken.Type != token.RPAREN && p.curToken.Type != token.EOF {
+		if len(list) > 0 && !p.expectToken(token.COMMA) {
+			p.fail("Invalid expression list, missing , between expressions", "',' or ')'", p.curToken)
+		}
 		list = append(list, p.parseExpression())
 		p.nextToken()
 	}
+	if p.curToken.Type != token.RPAREN {
+		p.fail("Invalid expression list, missing )", "')'", p.curToken)
+	}
 
 	return list
 }
@@ -473,22 +699,23 @@ func (p *Parser) parsePrefix() parseTree.Expression {
 	}
 	p.nextToken()
 	exp.Expression = p.parseTerm()
+	exp.Span = token.Span{Start: exp.Operator.Start, End: exp.Expression.Range().End}
 	return exp
 }
 
 func (p *Parser) parseIntegerConstant() parseTree.Expression {
 	val, err := strconv.Atoi(p.curToken.Literal)
 	if err != nil {
-		log.Fatalf("Error while converting %s to integer", p.curToken.Literal)
+		p.fail("Invalid integer constant", "integer constant", p.curToken)
 	}
 
-	return &parseTree.IntegerConstant{Token: p.curToken, Value: val}
+	return &parseTree.IntegerConstant{Span: p.curToken.Span, Token: p.curToken, Value: val}
 }
 
 func (p *Parser) parseKeywordConstant() parseTree.Expression {
-	return &parseTree.KeywordConstant{Token: p.curToken, Value: p.curToken.Literal}
+	return &parseTree.KeywordConstant{Span: p.curToken.Span, Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseStringConstant() parseTree.Expression {
-	return &parseTree.StringConstant{Token: p.curToken, Value: p.curToken.Literal}
+	return &parseTree.StringConstant{Span: p.curToken.Span, Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/tivt2/jack-compiler/parseTree"
 	"github.com/tivt2/jack-compiler/tokenizer"
 )
 
@@ -232,3 +234,245 @@ func TestParseStatement(t *testing.T) {
 // 		t.Fatalf("class.String() print wrong, expected: %s, received: %s", classTest.expect, class.String())
 // 	}
 // }
+
+func TestParseStatementSpan(t *testing.T) {
+	input := "while (x) {\n  let a[i] = -b + 1;\n}"
+
+	tkzr := tokenizer.New(input)
+	p := New(tkzr)
+
+	stmt := p.parseStatement()
+	ls := stmt.(*parseTree.WhileStatement).Stmts[0].(*parseTree.LetStatement)
+	infix := ls.Expression.(*parseTree.Infix)
+
+	tests := []struct {
+		node          parseTree.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{stmt, "1:1", "3:2"},
+		{ls, "2:3", "2:21"},
+		{ls.Ident, "2:7", "2:11"},
+		{infix, "2:14", "2:20"},
+		{infix.Left, "2:14", "2:16"},
+		{infix.Right, "2:19", "2:20"},
+	}
+
+	for i, test := range tests {
+		span := test.node.Range()
+		if span.Start.String() != test.expectedStart {
+			t.Fatalf("Start failed. test index %d, expected: %s, received: %s", i, test.expectedStart, span.Start)
+		}
+		if span.End.String() != test.expectedEnd {
+			t.Fatalf("End failed. test index %d, expected: %s, received: %s", i, test.expectedEnd, span.End)
+		}
+	}
+}
+
+func TestParseClassDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			`class Main {
+				function void main() {
+					return;
+				}
+			}`,
+			[]string{},
+		},
+		{
+			`class Main {
+				function void main() {
+					let x = 1
+					return;
+				}
+			}`,
+			[]string{"4:6: error: Invalid let statement, missing semicolon, expected ';', got keyword 'return'"},
+		},
+		{
+			`class Main {
+				field int x, 2;
+			}`,
+			[]string{"2:18: error: Invalid class var dec identifier, expected identifier, got integer constant 2"},
+		},
+		{
+			`class Main {
+				function void main() {
+					do Output.print(;
+				}
+			}`,
+			[]string{"3:22: error: Invalid term, expected term, got symbol ';'"},
+		},
+		{
+			`class Main {
+				function int main() {
+					return int;
+				}
+			}`,
+			[]string{"3:13: error: Invalid term, expected term, got keyword 'int'"},
+		},
+		{
+			`class Main {
+				function void main() {`,
+			[]string{"2:27: error: Invalid sub body statements, missing }, expected '}', got end of file"},
+		},
+		{
+			`class Main {
+				function void main() {
+					var Array a;
+					let a[1) = 2;
+					let a[1 = 2;
+					let a[1] = 2;
+					return;
+				}
+			}`,
+			[]string{
+				"4:13: error: Invalid let statement, missing ], expected ']', got symbol ')'",
+				"5:17: error: Invalid let statement, missing ], expected ']', got symbol ';'",
+			},
+		},
+		{
+			`class Main {
+				function void f(int a int b) {
+					return;
+				}
+				function void g(, int a) {
+					return;
+				}
+				function void h(int a,) {
+					return;
+				}
+				function void main() {
+					do Main.f(1 2);
+					do Main.f(, 1);
+					do Main.f(1, );
+					do Main.f(1, 2);
+					return;
+				}
+			}`,
+			[]string{
+				"2:27: error: Invalid sub dec, missing , between parameters, expected ',' or ')', got keyword 'int'",
+				"5:21: error: Invalid param dec type, expected type, got symbol ','",
+				"8:27: error: Invalid param dec type, expected type, got symbol ')'",
+				"12:18: error: Invalid expression list, missing , between expressions, expected ',' or ')', got integer constant 2",
+				"13:16: error: Invalid term, expected term, got symbol ','",
+				"14:19: error: Invalid term, expected term, got symbol ')'",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		tkzr := tokenizer.New(test.input)
+		p := New(tkzr)
+
+		_, diags := p.ParseClass()
+
+		if len(diags) != len(test.expected) {
+			t.Fatalf("ParseClass() test index %d, expected %d diagnostics, received: %v", i, len(test.expected), diags)
+		}
+		for j, d := range diags {
+			if d.String() != test.expected[j] {
+				t.Fatalf("ParseClass() test index %d, expected: %s, received: %s", i, test.expected[j], d.String())
+			}
+		}
+	}
+}
+
+func TestParseClassRecovery(t *testing.T) {
+	input := `class Main {
+	field int x
+	static boolean y;
+
+	function void main() {
+		var int a;
+		let a = ;
+		do Output.printInt(a)
+		let a = 5 +;
+		let = 5;
+		if (a { let a = 1; }
+		while (a > 0) {
+			let a = a - 1
+		}
+		return;
+	}
+
+	method void (int b) {
+		return;
+	}
+
+	method int get() {
+		var int ;
+		return x;
+	}
+}`
+
+	expected := []string{
+		"3:2: error: Invalid class var dec, missing semicolon, expected ';', got keyword 'static'",
+		"7:11: error: Invalid term, expected term, got symbol ';'",
+		"9:3: error: Invalid do statement, missing semicolon, expected ';', got keyword 'let'",
+		"9:14: error: Invalid term, expected term, got symbol ';'",
+		"10:7: error: Invalid let statement, missing ident, expected identifier, got symbol '='",
+		"11:9: error: Invalid if statement, missing ), expected ')', got symbol '{'",
+		"14:3: error: Invalid let statement, missing semicolon, expected ';', got symbol '}'",
+		"18:14: error: Invalid sub dec identifier, expected identifier, got symbol '('",
+		"23:11: error: Invalid var dec identifier, expected identifier, got symbol ';'",
+	}
+
+	tkzr := tokenizer.New(input)
+	p := New(tkzr)
+
+	class, diags := p.ParseClass()
+
+	if len(diags) != len(expected) {
+		t.Fatalf("ParseClass() expected %d diagnostics, received: %v", len(expected), diags)
+	}
+	for i, d := range diags {
+		if d.String() != expected[i] {
+			t.Fatalf("ParseClass() test index %d, expected: %s, received: %s", i, expected[i], d.String())
+		}
+	}
+
+	decs := map[string][]parseTree.Declaration{
+		"ClassVarDecs":   class.ClassVarDecs,
+		"SubroutineDecs": class.SubroutineDecs,
+	}
+	if len(class.SubroutineDecs) == 3 {
+		decs["VarDecs"] = class.SubroutineDecs[2].(*parseTree.SubroutineDec).SubroutineBody.VarDecs
+	}
+	expectedDecs := map[string][]string{
+		"ClassVarDecs":   {"<bad declaration>", "static boolean y;"},
+		"SubroutineDecs": {"function", "<bad declaration>", "method"},
+		"VarDecs":        {"<bad declaration>"},
+	}
+	for name, expected := range expectedDecs {
+		if len(decs[name]) != len(expected) {
+			t.Fatalf("%s wrong length, expected: %d, received: %v", name, len(expected), decs[name])
+		}
+		for i, dec := range decs[name] {
+			if !strings.HasPrefix(dec.String(), expected[i]) {
+				t.Fatalf("%s test index %d, expected: %s, received: %s", name, i, expected[i], dec.String())
+			}
+		}
+	}
+
+	stmts := class.Subroutines()[0].SubroutineBody.Statements
+	expectedStmts := []string{
+		"<bad statement>",
+		"<bad statement>",
+		"<bad statement>",
+		"<bad statement>",
+		"<bad statement>",
+		"while ((a > 0)) {\n<bad statement>\n}",
+		"return;",
+	}
+	if len(stmts) != len(expectedStmts) {
+		t.Fatalf("Statements wrong length, expected: %d, received: %d", len(expectedStmts), len(stmts))
+	}
+	for i, stmt := range stmts {
+		if stmt.String() != expectedStmts[i] {
+			t.Fatalf("Statements test index %d, expected: %s, received: %s", i, expectedStmts[i], stmt.String())
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package peephole
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Config struct {
+	Disabled []string
+	Void     func(name string) bool // reports whether a called function is void and returns 0
+}
+
+type Stats struct {
+	Before int
+	After  int
+}
+
+type Rule struct {
+	Name  string
+	Apply func(code []string, i int, config Config) (replacement []string, n int)
+}
+
+type Optimizer struct {
+	rules  []Rule
+	config Config
+}
+
+func New(config Config) *Optimizer {
+	o := &Optimizer{config: config}
+	for _, rule := range DefaultRules() {
+		o.Register(rule)
+	}
+	return o
+}
+
+func Optimize(code string, config Config) (string, Stats) {
+	return New(config).Optimize(code)
+}
+
+func (o *Optimizer) Register(rule Rule) {
+	for _, name := range o.config.Disabled {
+		if name == rule.Name {
+			return
+		}
+	}
+	o.rules = append(o.rules, rule)
+}
+
+func (o *Optimizer) Optimize(code string) (string, Stats) {
+	lines := strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+	stats := Stats{Before: Count(code)}
+
+	for changed := true; changed; {
+		changed = false
+		var out []string
+		for i := 0; i < len(lines); {
+			replacement, n := o.apply(lines, i)
+			if n == 0 {
+				out = append(out, lines[i])
+				i++
+				continue
+			}
+			out = append(out, replacement...)
+			i += n
+			changed = true
+		}
+		lines = out
+	}
+
+	out := strings.Join(lines, "\n") + "\n"
+	stats.After = Count(out)
+	return out, stats
+}
+
+func (o *Optimizer) apply(code []string, i int) ([]string, int) {
+	for _, rule := range o.rules {
+		if replacement, n := rule.Apply(code, i, o.config); n > 0 {
+			return replacement, n
+		}
+	}
+	return nil, 0
+}
+
+func Count(code string) int {
+	count := 0
+	for _, line := range strings.Split(code, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "//") {
+			count++
+		}
+	}
+	return count
+}
+
+func DefaultRules() []Rule {
+	return []Rule{
+		{"push-pop", pushPop},
+		{"double-negation", doubleNegation},
+		{"constant-branch", constantBranch},
+		{"invert-branch", invertBranch},
+		{"goto-label", gotoLabel},
+		{"unreachable", unreachable},
+		{"void-call", voidCall},
+	}
+}
+
+// push S i; pop S i
+func pushPop(code []string, i int, config Config) ([]string, int) {
+	push, pop := fields(code, i), fields(code, i+1)
+	if len(push) == 3 && len(pop) == 3 && push[0] == "push" && pop[0] == "pop" && push[1] == pop[1] && push[2] == pop[2] {
+		return nil, 2
+	}
+	return nil, 0
+}
+
+// not; not or neg; neg
+func doubleNegation(code []string, i int, config Config) ([]string, int) {
+	if i+1 < len(code) && (code[i] == "not" || code[i] == "neg") && code[i+1] == code[i] {
+		return nil, 2
+	}
+	return nil, 0
+}
+
+// push constant n, any neg or not, if-goto L
+func constantBranch(code []string, i int, config Config) ([]string, int) {
+	push := fields(code, i)
+	if len(push) != 3 || push[0] != "push" || push[1] != "constant" {
+		return nil, 0
+	}
+	v, err := strconv.Atoi(push[2])
+	if err != nil {
+		return nil, 0
+	}
+
+	j := i + 1
+	for ; j < len(code); j++ {
+		switch code[j] {
+		case "neg":
+			v = -v
+			continue
+		case "not":
+			v = ^v
+			continue
+		}
+		break
+	}
+
+	branch := fields(code, j)
+	if len(branch) != 2 || branch[0] != "if-goto" {
+		return nil, 0
+	}
+	if int16(v) == 0 {
+		return nil, j - i + 1
+	}
+	return []string{"goto " + branch[1]}, j - i + 1
+}
+
+// eq, lt or gt; not; if-goto A; goto B; label A, only a 0 or -1 comparison result can flip the branch
+func invertBranch(code []string, i int, config Config) ([]string, int) {
+	if i >= len(code) || code[i] != "not" || i == 0 || code[i-1] != "eq" && code[i-1] != "lt" && code[i-1] != "gt" {
+		return nil, 0
+	}
+	branch, jump, label := fields(code, i+1), fields(code, i+2), fields(code, i+3)
+	if len(branch) != 2 || branch[0] != "if-goto" || len(jump) != 2 || jump[0] != "goto" || len(label) != 2 || label[0] != "label" || label[1] != branch[1] {
+		return nil, 0
+	}
+	return []string{"if-goto " + jump[1], code[i+3]}, 4
+}
+
+// goto L; label L
+func gotoLabel(code []string, i int, config Config) ([]string, int) {
+	jump, label := fields(code, i), fields(code, i+1)
+	if len(jump) == 2 && len(label) == 2 && jump[0] == "goto" && label[0] == "label" && jump[1] == label[1] {
+		return []string{code[i+1]}, 2
+	}
+	return nil, 0
+}
+
+// anything after goto or return up to the next label or function
+func unreachable(code []string, i int, config Config) ([]string, int) {
+	if op := fields(code, i); len(op) == 0 || op[0] != "goto" && op[0] != "return" {
+		return nil, 0
+	}
+	j := i + 1
+	for ; j < len(code); j++ {
+		if op := fields(code, j); len(op) == 0 || op[0] == "label" || op[0] == "function" || strings.HasPrefix(op[0], "//") {
+			break
+		}
+	}
+	if j == i+1 {
+		return nil, 0
+	}
+	return []string{code[i]}, j - i
+}
+
+// call F n; pop temp 0; push constant 0 where F is void
+func voidCall(code []string, i int, config Config) ([]string, int) {
+	if config.Void == nil {
+		return nil, 0
+	}
+	call := fields(code, i)
+	if len(call) != 3 || call[0] != "call" || !config.Void(call[1]) {
+		return nil, 0
+	}
+	if i+2 < len(code) && code[i+1] == "pop temp 0" && code[i+2] == "push constant 0" {
+		return []string{code[i]}, 3
+	}
+	return nil, 0
+}
+
+func fields(code []string, i int) []string {
+	if i >= len(code) {
+		return nil
+	}
+	return strings.Fields(code[i])
+}
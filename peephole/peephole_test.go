@@ -0,0 +1,90 @@
+package peephole
+
+import "testing"
+
+func TestOptimize(t *testing.T) {
+	void := func(name string) bool { return name == "Output.println" }
+	tests := []struct {
+		input    string
+		config   Config
+		expected string
+		stats    Stats
+	}{
+		{
+			"push local 0\npop local 0\npush local 0\npop local 1\n",
+			Config{},
+			"push local 0\npop local 1\n",
+			Stats{4, 2},
+		},
+		{
+			"push local 0\nnot\nnot\nneg\nneg\nreturn\n",
+			Config{},
+			"push local 0\nreturn\n",
+			Stats{6, 2},
+		},
+		{
+			"label WHILE0\npush constant 1\nneg\nnot\nif-goto BREAK0\ngoto WHILE0\nlabel BREAK0\n",
+			Config{},
+			"label WHILE0\ngoto WHILE0\nlabel BREAK0\n",
+			Stats{7, 3},
+		},
+		{
+			"push constant 0\nnot\nif-goto L\npush local 0\nlabel L\n",
+			Config{},
+			"label L\n",
+			Stats{5, 1},
+		},
+		{
+			"push local 0\npush local 1\neq\nnot\nif-goto A\ngoto B\nlabel A\n",
+			Config{},
+			"push local 0\npush local 1\neq\nif-goto B\nlabel A\n",
+			Stats{7, 5},
+		},
+		{
+			"push local 0\nnot\nif-goto A\ngoto B\nlabel A\n",
+			Config{},
+			"push local 0\nnot\nif-goto A\ngoto B\nlabel A\n",
+			Stats{5, 5},
+		},
+		{
+			"push constant 1\nnot\nif-goto A\ngoto B\nlabel A\n",
+			Config{},
+			"label A\n",
+			Stats{5, 1},
+		},
+		{
+			"push constant 1\nnot\nif-goto A\ngoto B\nlabel A\n",
+			Config{Disabled: []string{"constant-branch"}},
+			"push constant 1\nnot\nif-goto A\ngoto B\nlabel A\n",
+			Stats{5, 5},
+		},
+		{
+			"goto IF0\nlabel IF0\nreturn\npush constant 0\nfunction Main.f 0\n",
+			Config{},
+			"label IF0\nreturn\nfunction Main.f 0\n",
+			Stats{5, 3},
+		},
+		{
+			"call Output.println 0\npop temp 0\npush constant 0\nreturn\nfunction Main.g 0\ncall Main.f 0\npop temp 0\npush constant 0\nreturn\n",
+			Config{Void: void},
+			"call Output.println 0\nreturn\nfunction Main.g 0\ncall Main.f 0\npop temp 0\npush constant 0\nreturn\n",
+			Stats{9, 7},
+		},
+		{
+			"// class Main\npush local 0\npop local 0\ngoto L\nlabel L\n",
+			Config{Disabled: []string{"push-pop"}},
+			"// class Main\npush local 0\npop local 0\nlabel L\n",
+			Stats{4, 3},
+		},
+	}
+
+	for _, test := range tests {
+		out, stats := Optimize(test.input, test.config)
+		if out != test.expected {
+			t.Fatalf("Optimize()\n\nexpected:\n%s\n\nreceived:\n%s", test.expected, out)
+		}
+		if stats != test.stats {
+			t.Fatalf("Optimize() expected stats %v, received: %v", test.stats, stats)
+		}
+	}
+}
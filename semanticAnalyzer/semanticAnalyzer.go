@@ -0,0 +1,198 @@
+package semanticAnalyzer
+
+import (
+	"fmt"
+
+	"github.com/tivt2/jack-compiler/classIndex"
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/symbolTable"
+	"github.com/tivt2/jack-compiler/token"
+)
+
+type Analyzer struct {
+	c       *parseTree.Class
+	s       *symbolTable.SymbolTable
+	idx     *classIndex.Index
+	current *parseTree.SubroutineDec
+
+	diagnostics []diagnostic.Diagnostic
+}
+
+func New(c *parseTree.Class, idx *classIndex.Index) *Analyzer {
+	return &Analyzer{
+		c:   c,
+		s:   symbolTable.New(),
+		idx: idx,
+	}
+}
+
+func Analyze(c *parseTree.Class, idx *classIndex.Index) []diagnostic.Diagnostic {
+	return New(c, idx).Analyze()
+}
+
+func (a *Analyzer) Analyze() []diagnostic.Diagnostic {
+	for _, dec := range a.c.ClassVars() {
+		a.checkType(dec.DecType)
+	}
+	a.diagnostics = append(a.diagnostics, a.s.DefineClass(a.c)...)
+	for _, sd := range a.c.Subroutines() {
+		a.analyzeSubroutineDec(sd)
+	}
+	return a.diagnostics
+}
+
+func (a *Analyzer) analyzeSubroutineDec(sd *parseTree.SubroutineDec) {
+	a.current = sd
+	a.checkType(sd.DecType)
+	for _, param := range sd.Params {
+		a.checkType(param.DecType)
+	}
+	for _, varDec := range sd.SubroutineBody.Vars() {
+		a.checkType(varDec.DecType)
+	}
+	a.diagnostics = append(a.diagnostics, a.s.DefineSubroutine(a.c, sd)...)
+
+	a.analyzeStatements(sd.SubroutineBody.Statements)
+}
+
+func (a *Analyzer) analyzeStatements(stmts []parseTree.Statement) {
+	for _, stmt := range stmts {
+		a.analyzeStatement(stmt)
+	}
+}
+
+func (a *Analyzer) analyzeStatement(stmt parseTree.Statement) {
+	switch stmt := stmt.(type) {
+	case *parseTree.LetStatement:
+		a.analyzeExpression(stmt.Ident)
+		a.analyzeExpression(stmt.Expression)
+	case *parseTree.ReturnStatement:
+		if stmt.Expression != nil {
+			a.analyzeExpression(stmt.Expression)
+		}
+	case *parseTree.DoStatement:
+		a.analyzeExpression(stmt.Expression)
+	case *parseTree.WhileStatement:
+		a.analyzeExpression(stmt.Expression)
+		a.analyzeStatements(stmt.Stmts)
+	case *parseTree.IfStatement:
+		a.analyzeExpression(stmt.Expression)
+		a.analyzeStatements(stmt.IfStmts)
+		a.analyzeStatements(stmt.Else)
+	}
+}
+
+func (a *Analyzer) analyzeExpression(exp parseTree.Expression) {
+	switch exp := exp.(type) {
+	case *parseTree.Prefix:
+		a.analyzeExpression(exp.Expression)
+	case *parseTree.Group:
+		a.analyzeExpression(exp.Expression)
+	case *parseTree.Infix:
+		a.analyzeExpression(exp.Left)
+		a.analyzeExpression(exp.Right)
+	case *parseTree.Identifier:
+		if a.s.KindOf(exp.Value) == "" {
+			a.report(exp.Span, fmt.Sprintf("Undefined variable '%s'", exp.Value), "declare it with 'var', as a parameter or as a field/static of the class")
+		}
+		if exp.Indexer != nil {
+			a.analyzeExpression(exp.Indexer)
+		}
+	case *parseTree.SubroutineCall:
+		a.analyzeCall(exp)
+		for _, e := range exp.ExpList {
+			a.analyzeExpression(e)
+		}
+	}
+}
+
+func (a *Analyzer) analyzeCall(exp *parseTree.SubroutineCall) {
+	switch {
+	case exp.Ident == nil:
+		sub, ok := a.lookup(a.c.Ident.Value, exp)
+		if !ok {
+			return
+		}
+		if sub.Kind != token.METHOD {
+			a.report(exp.Subroutine.Span, fmt.Sprintf("Function %s called as a method", sub.FullName()), fmt.Sprintf("call it as %s(...)", sub.FullName()))
+		} else if a.current.Kind.Type == token.FUNCTION {
+			a.report(exp.Subroutine.Span, fmt.Sprintf("Method %s called from function %s.%s", sub.FullName(), a.c.Ident.Value, a.current.Ident.Value), "methods need an object, call it through a variable")
+		}
+		a.checkArity(sub, exp)
+	case a.s.KindOf(exp.Ident.Value) != "":
+		decType := a.s.TypeOf(exp.Ident.Value)
+		if decType == "int" || decType == "char" || decType == "boolean" {
+			a.report(exp.Ident.Span, fmt.Sprintf("Cannot call a method on '%s' of type %s", exp.Ident.Value, decType), "")
+			return
+		}
+		if _, ok := a.idx.Class(decType); !ok {
+			return
+		}
+		sub, ok := a.lookup(decType, exp)
+		if !ok {
+			return
+		}
+		if sub.Kind != token.METHOD {
+			a.report(exp.Subroutine.Span, fmt.Sprintf("Function %s called as a method", sub.FullName()), fmt.Sprintf("call it as %s(...)", sub.FullName()))
+		}
+		a.checkArity(sub, exp)
+	default:
+		if _, ok := a.idx.Class(exp.Ident.Value); !ok {
+			a.report(exp.Ident.Span, fmt.Sprintf("Undefined class or variable '%s'", exp.Ident.Value), "")
+			return
+		}
+		sub, ok := a.lookup(exp.Ident.Value, exp)
+		if !ok {
+			return
+		}
+		if sub.Kind == token.METHOD {
+			a.report(exp.Subroutine.Span, fmt.Sprintf("Method %s called as a function", sub.FullName()), fmt.Sprintf("call it on an object of type %s", sub.Class))
+		}
+		a.checkArity(sub, exp)
+	}
+}
+
+func (a *Analyzer) lookup(className string, exp *parseTree.SubroutineCall) (*classIndex.Subroutine, bool) {
+	sub, ok := a.idx.Subroutine(className, exp.Subroutine.Value)
+	if !ok {
+		suggestion := ""
+		if name := a.idx.Suggest(className, exp.Subroutine.Value); name != "" {
+			suggestion = fmt.Sprintf("did you mean '%s'?", name)
+		}
+		a.report(exp.Subroutine.Span, fmt.Sprintf("Undefined subroutine '%s' in class %s", exp.Subroutine.Value, className), suggestion)
+	}
+	return sub, ok
+}
+
+func (a *Analyzer) checkArity(sub *classIndex.Subroutine, exp *parseTree.SubroutineCall) {
+	if len(sub.Params) == len(exp.ExpList) {
+		return
+	}
+	a.diagnostics = append(a.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.ERROR,
+		Message:  fmt.Sprintf("Wrong number of arguments in call to %s", sub.FullName()),
+		Expected: fmt.Sprintf("%d", len(sub.Params)),
+		Got:      fmt.Sprintf("%d", len(exp.ExpList)),
+		Span:     exp.Span,
+	})
+}
+
+func (a *Analyzer) checkType(decType token.Token) {
+	if decType.Type != token.IDENT {
+		return
+	}
+	if _, ok := a.idx.Class(decType.Literal); ok {
+		return
+	}
+	a.report(decType.Span, fmt.Sprintf("Undefined class '%s'", decType.Literal), "")
+}
+
+func (a *Analyzer) report(span token.Span, msg string, suggestion string) {
+	a.diagnostics = append(a.diagnostics, diagnostic.Diagnostic{
+		Severity:   diagnostic.ERROR,
+		Message:    msg,
+		Suggestion: suggestion,
+		Span:       span,
+	})
+}
@@ -0,0 +1,193 @@
+package semanticAnalyzer
+
+import (
+	"testing"
+
+	"github.com/tivt2/jack-compiler/classIndex"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+const point = `class Point {
+	constructor Point new(int x, int y) { return this; }
+	method void draw() { return; }
+	function int count() { return 0; }
+}`
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		input    string
+		others   []string
+		expected []string
+	}{
+		{
+			`class Main {
+				static int count;
+				function void main() {
+					var Point p;
+					let count = count + 1;
+					let p = Point.new(count, Point.count());
+					do p.draw();
+					do Main.helper(Math.abs(count));
+					return;
+				}
+				function void helper(int x) { return; }
+			}`,
+			[]string{point},
+			[]string{},
+		},
+		{
+			`class Main {
+				function void main() {
+					var int x;
+					let y = x + z[x];
+					return;
+				}
+			}`,
+			nil,
+			[]string{
+				"4:10: error: Undefined variable 'y' (hint: declare it with 'var', as a parameter or as a field/static of the class)",
+				"4:18: error: Undefined variable 'z' (hint: declare it with 'var', as a parameter or as a field/static of the class)",
+			},
+		},
+		{
+			`class Main {
+				function void main() {
+					do helper();
+					do Point.new();
+					return;
+				}
+			}`,
+			nil,
+			[]string{
+				"3:9: error: Undefined subroutine 'helper' in class Main",
+				"4:9: error: Undefined class or variable 'Point'",
+			},
+		},
+		{
+			`class Main {
+				field Point p;
+				method Point get(Line l) {
+					var Circle c;
+					return p;
+				}
+			}`,
+			[]string{point},
+			[]string{
+				"3:22: error: Undefined class 'Line'",
+				"4:10: error: Undefined class 'Circle'",
+			},
+		},
+		{
+			`class Main {
+				function void main() {
+					var Point p;
+					var int x;
+					let p = Point.new(1);
+					do p.draw(1, 2);
+					do p.count();
+					do Point.draw();
+					do p.erase();
+					do x.draw();
+					do helper();
+					do run();
+					return;
+				}
+				function void helper() { return; }
+				method void run() { do run(); return; }
+			}`,
+			[]string{point},
+			[]string{
+				"5:14: error: Wrong number of arguments in call to Point.new, expected 2, got 1",
+				"6:9: error: Wrong number of arguments in call to Point.draw, expected 0, got 2",
+				"7:11: error: Function Point.count called as a method (hint: call it as Point.count(...))",
+				"8:15: error: Method Point.draw called as a function (hint: call it on an object of type Point)",
+				"9:11: error: Undefined subroutine 'erase' in class Point",
+				"10:9: error: Cannot call a method on 'x' of type int",
+				"11:9: error: Function Main.helper called as a method (hint: call it as Main.helper(...))",
+				"12:9: error: Method Main.run called from function Main.main (hint: methods need an object, call it through a variable)",
+			},
+		},
+		{
+			`class Main {
+				function void main() {
+					var String s;
+					let s = String.new(3);
+					do s.appendChar(65);
+					do Output.printLn();
+					do Output.printInt(1, 2);
+					do String.length();
+					do Math.sqroot(4);
+					do Screen.drawCircle(1, 2, 3);
+					return;
+				}
+			}`,
+			nil,
+			[]string{
+				"6:16: error: Undefined subroutine 'printLn' in class Output (hint: did you mean 'println'?)",
+				"7:9: error: Wrong number of arguments in call to Output.printInt, expected 1, got 2",
+				"8:16: error: Method String.length called as a function (hint: call it on an object of type String)",
+				"9:14: error: Undefined subroutine 'sqroot' in class Math (hint: did you mean 'sqrt'?)",
+			},
+		},
+		{
+			`class Main {
+				field int x, x;
+				method void set(int x, int y) {
+					var int y;
+					let x = y;
+					return;
+				}
+			}`,
+			nil,
+			[]string{
+				"2:18: error: Duplicate declaration of 'x', first declared at 2:15",
+				"3:25: warning: Declaration of argument 'x' shadows the field declared at 2:15",
+				"4:14: error: Duplicate declaration of 'y', first declared at 3:32",
+			},
+		},
+		{
+			`class Main {
+				function void main() {
+					do Math.abs(1, 2);
+					return;
+				}
+			}`,
+			[]string{`class Math {
+				function int abs(int x, int y) { return x; }
+			}`},
+			[]string{},
+		},
+	}
+
+	for i, test := range tests {
+		idx := classIndex.New()
+		for _, other := range test.others {
+			idx.Add(parse(t, other))
+		}
+		class := parse(t, test.input)
+		idx.Add(class)
+
+		diags := Analyze(class, idx)
+
+		if len(diags) != len(test.expected) {
+			t.Fatalf("Analyze() test index %d, expected %d diagnostics, received: %v", i, len(test.expected), diags)
+		}
+		for j, d := range diags {
+			if d.String() != test.expected[j] {
+				t.Fatalf("Analyze() test index %d, expected: %s, received: %s", i, test.expected[j], d.String())
+			}
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *parseTree.Class {
+	tkzr := tokenizer.New(input)
+	p := parser.New(tkzr)
+	class, diags := p.ParseClass()
+	if len(diags) != 0 {
+		t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+	}
+	return class
+}
@@ -1,13 +1,32 @@
 package symbolTable
 
 import (
+	"fmt"
 	"log"
+	"sort"
+
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/token"
 )
 
 type tableRow struct {
 	DecType string
 	kind    string
 	id      int
+	span    token.Span
+
+	reads  int
+	writes int
+}
+
+type Symbol struct {
+	Name    string
+	DecType string
+	Kind    string
+	Reads   int
+	Writes  int
+	token.Span
 }
 
 type SymbolTable struct {
@@ -29,29 +48,88 @@ func New() *SymbolTable {
 
 func (sb *SymbolTable) Reset() {
 	sb.subroutineLevel = make(map[string]*tableRow)
-	sb.staticCounter = 0
-	sb.fieldCounter = 0
 	sb.argumentCounter = 0
 	sb.localCounter = 0
 }
 
-func (sb *SymbolTable) Define(name string, decType string, kind string) {
+func (sb *SymbolTable) Define(name string, decType string, kind string, span token.Span) *diagnostic.Diagnostic {
+	classLevel := kind == "field" || kind == "static"
+	table := sb.subroutineLevel
+	if classLevel {
+		table = sb.classLevel
+	}
+	if row, ok := table[name]; ok {
+		return &diagnostic.Diagnostic{
+			Severity: diagnostic.ERROR,
+			Message:  fmt.Sprintf("Duplicate declaration of '%s', first declared at %s", name, row.span.Start),
+			Span:     span,
+		}
+	}
+
 	switch kind {
 	case "field":
-		sb.classLevel[name] = &tableRow{kind: "this", id: sb.fieldCounter, DecType: decType}
+		sb.classLevel[name] = &tableRow{kind: "this", id: sb.fieldCounter, DecType: decType, span: span}
 		sb.fieldCounter++
 	case "static":
-		sb.classLevel[name] = &tableRow{kind: kind, id: sb.staticCounter, DecType: decType}
+		sb.classLevel[name] = &tableRow{kind: kind, id: sb.staticCounter, DecType: decType, span: span}
 		sb.staticCounter++
 	case "argument":
-		sb.subroutineLevel[name] = &tableRow{kind: kind, id: sb.argumentCounter, DecType: decType}
+		sb.subroutineLevel[name] = &tableRow{kind: kind, id: sb.argumentCounter, DecType: decType, span: span}
 		sb.argumentCounter++
 	case "local":
-		sb.subroutineLevel[name] = &tableRow{kind: kind, id: sb.localCounter, DecType: decType}
+		sb.subroutineLevel[name] = &tableRow{kind: kind, id: sb.localCounter, DecType: decType, span: span}
 		sb.localCounter++
 	default:
 		log.Fatalf("Wrong table data, received: {name: %s,decType: %s,kind: %s}", name, decType, kind)
 	}
+
+	if row, ok := sb.classLevel[name]; ok && !classLevel {
+		return &diagnostic.Diagnostic{
+			Severity: diagnostic.WARNING,
+			Message:  fmt.Sprintf("Declaration of %s '%s' shadows the %s declared at %s", kind, name, classKind(row.kind), row.span.Start),
+			Span:     span,
+		}
+	}
+	return nil
+}
+
+func (sb *SymbolTable) DefineClass(c *parseTree.Class) []diagnostic.Diagnostic {
+	var diags []diagnostic.Diagnostic
+	for _, dec := range c.ClassVars() {
+		diags = appendDiagnostic(diags, sb.Define(dec.Ident.Value, dec.DecType.Literal, dec.Kind.Literal, dec.Ident.Span))
+	}
+	return diags
+}
+
+// DefineSubroutine resets the subroutine scope and defines this for methods, then the parameters and locals of sd
+func (sb *SymbolTable) DefineSubroutine(c *parseTree.Class, sd *parseTree.SubroutineDec) []diagnostic.Diagnostic {
+	sb.Reset()
+	if sd.Kind.Type == token.METHOD {
+		sb.Define("this", c.Ident.Value, "argument", token.Span{})
+	}
+
+	var diags []diagnostic.Diagnostic
+	for _, param := range sd.Params {
+		diags = appendDiagnostic(diags, sb.Define(param.Ident.Value, param.DecType.Literal, "argument", param.Ident.Span))
+	}
+	for _, varDec := range sd.SubroutineBody.Vars() {
+		diags = appendDiagnostic(diags, sb.Define(varDec.Ident.Value, varDec.DecType.Literal, "local", varDec.Ident.Span))
+	}
+	return diags
+}
+
+func appendDiagnostic(diags []diagnostic.Diagnostic, d *diagnostic.Diagnostic) []diagnostic.Diagnostic {
+	if d != nil {
+		diags = append(diags, *d)
+	}
+	return diags
+}
+
+func classKind(kind string) string {
+	if kind == "this" {
+		return "field"
+	}
+	return kind
 }
 
 func (sb *SymbolTable) VarCount(kind string) int {
@@ -72,9 +150,9 @@ func (sb *SymbolTable) VarCount(kind string) int {
 }
 
 func (sb *SymbolTable) KindOf(name string) string {
-	if row, ok := sb.classLevel[name]; ok {
+	if row, ok := sb.subroutineLevel[name]; ok {
 		return row.kind
-	} else if row, ok := sb.subroutineLevel[name]; ok {
+	} else if row, ok := sb.classLevel[name]; ok {
 		return row.kind
 	} else {
 		return ""
@@ -82,9 +160,9 @@ func (sb *SymbolTable) KindOf(name string) string {
 }
 
 func (sb *SymbolTable) TypeOf(name string) string {
-	if row, ok := sb.classLevel[name]; ok {
+	if row, ok := sb.subroutineLevel[name]; ok {
 		return row.DecType
-	} else if row, ok := sb.subroutineLevel[name]; ok {
+	} else if row, ok := sb.classLevel[name]; ok {
 		return row.DecType
 	} else {
 		return ""
@@ -92,11 +170,59 @@ func (sb *SymbolTable) TypeOf(name string) string {
 }
 
 func (sb *SymbolTable) IndexOf(name string) int {
-	if row, ok := sb.classLevel[name]; ok {
+	if row, ok := sb.subroutineLevel[name]; ok {
 		return row.id
-	} else if row, ok := sb.subroutineLevel[name]; ok {
+	} else if row, ok := sb.classLevel[name]; ok {
 		return row.id
 	} else {
 		return -1
 	}
 }
+
+func (sb *SymbolTable) Read(name string) {
+	if row := sb.lookup(name); row != nil {
+		row.reads++
+	}
+}
+
+func (sb *SymbolTable) Write(name string) {
+	if row := sb.lookup(name); row != nil {
+		row.writes++
+	}
+}
+
+func (sb *SymbolTable) ClassSymbols() []Symbol {
+	return symbols(sb.classLevel)
+}
+
+func (sb *SymbolTable) SubroutineSymbols() []Symbol {
+	return symbols(sb.subroutineLevel)
+}
+
+func (sb *SymbolTable) lookup(name string) *tableRow {
+	if row, ok := sb.subroutineLevel[name]; ok {
+		return row
+	}
+	return sb.classLevel[name]
+}
+
+func symbols(table map[string]*tableRow) []Symbol {
+	var out []Symbol
+	for name, row := range table {
+		out = append(out, Symbol{
+			Name:    name,
+			DecType: row.DecType,
+			Kind:    classKind(row.kind),
+			Reads:   row.reads,
+			Writes:  row.writes,
+			Span:    row.span,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Start.Offset != out[j].Start.Offset {
+			return out[i].Start.Offset < out[j].Start.Offset
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
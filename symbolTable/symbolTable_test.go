@@ -0,0 +1,133 @@
+package symbolTable
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/token"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+func span(line int, column int) token.Span {
+	pos := token.Position{Line: line, Column: column}
+	return token.Span{Start: pos, End: pos}
+}
+
+func TestDefine(t *testing.T) {
+	s := New()
+
+	tests := []struct {
+		name     string
+		kind     string
+		span     token.Span
+		expected string
+	}{
+		{"x", "field", span(2, 13), ""},
+		{"count", "static", span(3, 14), ""},
+		{"x", "static", span(4, 14), "4:14: error: Duplicate declaration of 'x', first declared at 2:13"},
+		{"a", "argument", span(5, 20), ""},
+		{"a", "local", span(6, 9), "6:9: error: Duplicate declaration of 'a', first declared at 5:20"},
+		{"x", "local", span(7, 9), "7:9: warning: Declaration of local 'x' shadows the field declared at 2:13"},
+		{"count", "argument", span(8, 9), "8:9: warning: Declaration of argument 'count' shadows the static declared at 3:14"},
+	}
+
+	for i, test := range tests {
+		d := s.Define(test.name, "int", test.kind, test.span)
+		received := ""
+		if d != nil {
+			received = d.String()
+		}
+		if received != test.expected {
+			t.Fatalf("Define() test index %d, expected: %q, received: %q", i, test.expected, received)
+		}
+	}
+
+	if s.KindOf("x") != "local" || s.IndexOf("x") != 0 {
+		t.Fatalf("Define() shadowing local not found first, received: %s %d", s.KindOf("x"), s.IndexOf("x"))
+	}
+	if s.IndexOf("a") != 0 || s.KindOf("a") != "argument" {
+		t.Fatalf("Define() duplicate overwrote 'a', received: %s %d", s.KindOf("a"), s.IndexOf("a"))
+	}
+	if s.VarCount("static") != 1 || s.VarCount("local") != 1 {
+		t.Fatalf("Define() duplicate bumped counters, received: static %d, local %d", s.VarCount("static"), s.VarCount("local"))
+	}
+
+	s.Reset()
+	if s.KindOf("x") != "this" || s.IndexOf("x") != 0 {
+		t.Fatalf("Define() duplicate overwrote 'x', received: %s %d", s.KindOf("x"), s.IndexOf("x"))
+	}
+	if d := s.Define("y", "int", "field", span(9, 13)); d != nil || s.IndexOf("y") != 1 {
+		t.Fatalf("Reset() cleared class level counters, received: %v %d", d, s.IndexOf("y"))
+	}
+}
+
+func TestDefineSubroutine(t *testing.T) {
+	input := `class Main {
+		field int x;
+		static int x;
+		method void f(int a, int x) {
+			var int b, a;
+			return;
+		}
+		function void g(int c) {
+			return;
+		}
+	}`
+	class, diags := parser.New(tokenizer.New(input)).ParseClass()
+	if len(diags) != 0 {
+		t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+	}
+	subs := class.Subroutines()
+	s := New()
+
+	tests := []struct {
+		diags    []string
+		expected map[string]string
+	}{
+		{
+			[]string{"3:14: error: Duplicate declaration of 'x', first declared at 2:13"},
+			map[string]string{"x": "this 0"},
+		},
+		{
+			[]string{
+				"4:28: warning: Declaration of argument 'x' shadows the field declared at 2:13",
+				"5:15: error: Duplicate declaration of 'a', first declared at 4:21",
+			},
+			map[string]string{"this": "argument 0", "a": "argument 1", "x": "argument 2", "b": "local 0"},
+		},
+		{
+			nil,
+			map[string]string{"this": "", "a": "", "c": "argument 0", "x": "this 0"},
+		},
+	}
+
+	for i, test := range tests {
+		var received []diagnostic.Diagnostic
+		switch i {
+		case 0:
+			received = s.DefineClass(class)
+		default:
+			received = s.DefineSubroutine(class, subs[i-1])
+		}
+
+		if len(received) != len(test.diags) {
+			t.Fatalf("DefineSubroutine() test index %d, expected %d diagnostics, received: %v", i, len(test.diags), received)
+		}
+		for j, d := range received {
+			if d.String() != test.diags[j] {
+				t.Fatalf("DefineSubroutine() test index %d, expected: %s, received: %s", i, test.diags[j], d.String())
+			}
+		}
+		for name, expected := range test.expected {
+			kind := s.KindOf(name)
+			if kind != "" {
+				kind = fmt.Sprintf("%s %d", kind, s.IndexOf(name))
+			}
+			if kind != expected {
+				t.Fatalf("DefineSubroutine() test index %d, expected %s to be %q, received: %q", i, name, expected, kind)
+			}
+		}
+	}
+}
@@ -2,41 +2,49 @@ package syntaxAnalyzer
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"regexp"
-	"strings"
 
+	"github.com/tivt2/jack-compiler/diagnostic"
 	"github.com/tivt2/jack-compiler/parseTree"
 	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/token"
 	"github.com/tivt2/jack-compiler/tokenizer"
 )
 
-func ParseTree(filePath string) *parseTree.Class {
-	file, err := os.ReadFile(filePath)
+func ParseTree(filePath string) (*parseTree.Class, []diagnostic.Diagnostic) {
+	file, err := os.Open(filePath)
 	checkErr(err, fmt.Sprintf("Error when opening file %s", filePath))
+	defer file.Close()
 
-	fileContent := removeComments(strings.TrimSpace(string(file)))
+	return ParseReader(filePath, file)
+}
 
-	tkzr := tokenizer.New(fileContent)
+func ParseReader(filename string, r io.Reader) (*parseTree.Class, []diagnostic.Diagnostic) {
+	tkzr := tokenizer.NewReader(filename, r)
 	parser := parser.New(tkzr)
 
 	return parser.ParseClass()
 }
 
-func removeComments(text string) string {
-	regexes := []string{
-		`\/\*[^*]*\*\/`,
-		`\/\*\*[\s\S]*?\*\/`,
-		`\/\/[^\n]*`,
-	}
+func Tokens(filePath string) ([]token.Token, []diagnostic.Diagnostic) {
+	file, err := os.Open(filePath)
+	checkErr(err, fmt.Sprintf("Error when opening file %s", filePath))
+	defer file.Close()
+
+	tkzr := tokenizer.NewReader(filePath, file)
 
-	for _, pattern := range regexes {
-		regex := regexp.MustCompile(pattern)
-		text = regex.ReplaceAllString(text, "")
+	var tokens []token.Token
+	for {
+		tk := tkzr.Advance()
+		tokens = append(tokens, tk)
+		if tk.Type == token.EOF {
+			break
+		}
 	}
 
-	return text
+	return tokens, tkzr.Diagnostics()
 }
 
 func checkErr(err error, msg string) {
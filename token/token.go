@@ -1,20 +1,82 @@
 package token
 
+import (
+	"bytes"
+	"fmt"
+)
+
 type TokenType string
 
+type Category string
+
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (p Position) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) Range() Span { return s }
+
+type TriviaKind string
+
+const (
+	WHITESPACE    = "whitespace"
+	LINE_COMMENT  = "lineComment"
+	BLOCK_COMMENT = "blockComment"
+	DOC_COMMENT   = "docComment"
+)
+
+type Trivia struct {
+	Kind     TriviaKind
+	Text     string
+	Trailing bool // on the same line as the preceding token
+	Span
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Raw     string
+	Span
+
+	LeadingTrivia  []Trivia
+	TrailingTrivia []Trivia
+}
+
+func (t Token) FullText() string {
+	var out bytes.Buffer
+
+	for _, tr := range t.LeadingTrivia {
+		out.WriteString(tr.Text)
+	}
+	out.WriteString(t.Raw)
+	for _, tr := range t.TrailingTrivia {
+		out.WriteString(tr.Text)
+	}
+
+	return out.String()
 }
 
 const (
 	ILLEGAL = "illegal"
 	EOF     = "eof"
 
-	IDENT   = "ident"
-	INT     = "int"
-	CHAR    = "char"
-	BOOLEAN = "boolean"
+	IDENT        = "ident"
+	INT_CONST    = "integerConstant"
+	STRING_CONST = "stringConstant"
 
 	ASSIGN    = "="
 	LBRACKET  = "["
@@ -35,7 +97,6 @@ const (
 	LT        = "<"
 	GT        = ">"
 	NOT       = "~"
-	QUOT      = `"`
 
 	CLASS       = "class"
 	CONSTRUCTOR = "constructor"
@@ -44,6 +105,9 @@ const (
 	FIELD       = "field"
 	STATIC      = "static"
 	VAR         = "var"
+	INT         = "int"
+	CHAR        = "char"
+	BOOLEAN     = "boolean"
 	VOID        = "void"
 	TRUE        = "true"
 	FALSE       = "false"
@@ -57,6 +121,36 @@ const (
 	RETURN      = "return"
 )
 
+const (
+	KEYWORD    Category = "keyword"
+	SYMBOL     Category = "symbol"
+	IDENTIFIER Category = "identifier"
+	INTEGER    Category = "integerConstant"
+	STRING     Category = "stringConstant"
+)
+
+var symbols = map[TokenType]bool{
+	ASSIGN:    true,
+	LBRACKET:  true,
+	RBRACKET:  true,
+	LPAREN:    true,
+	RPAREN:    true,
+	LBRACE:    true,
+	RBRACE:    true,
+	DOT:       true,
+	COMMA:     true,
+	SEMICOLON: true,
+	PLUS:      true,
+	MINUS:     true,
+	ASTERISK:  true,
+	FSLASH:    true,
+	AMP:       true,
+	BAR:       true,
+	LT:        true,
+	GT:        true,
+	NOT:       true,
+}
+
 var keywords = map[string]TokenType{
 	"class":       CLASS,
 	"constructor": CONSTRUCTOR,
@@ -87,3 +181,41 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+func (t TokenType) Category() Category {
+	switch {
+	case t == IDENT:
+		return IDENTIFIER
+	case t == INT_CONST:
+		return INTEGER
+	case t == STRING_CONST:
+		return STRING
+	case symbols[t]:
+		return SYMBOL
+	case keywords[string(t)] == t:
+		return KEYWORD
+	default:
+		return ""
+	}
+}
+
+func (t Token) Category() Category { return t.Type.Category() }
+
+func (t Token) Describe() string {
+	switch t.Category() {
+	case KEYWORD:
+		return fmt.Sprintf("keyword '%s'", t.Literal)
+	case SYMBOL:
+		return fmt.Sprintf("symbol '%s'", t.Literal)
+	case IDENTIFIER:
+		return fmt.Sprintf("identifier '%s'", t.Literal)
+	case INTEGER:
+		return fmt.Sprintf("integer constant %s", t.Literal)
+	case STRING:
+		return fmt.Sprintf("string constant \"%s\"", t.Literal)
+	}
+	if t.Type == EOF {
+		return "end of file"
+	}
+	return fmt.Sprintf("illegal character '%s'", t.Literal)
+}
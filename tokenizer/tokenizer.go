@@ -1,36 +1,159 @@
 package tokenizer
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tivt2/jack-compiler/diagnostic"
 	"github.com/tivt2/jack-compiler/token"
 )
 
+const maxInt = 32767
+
 type Tokenizer struct {
-	input        string
+	filename     string
+	reader       *bufio.Reader
 	position     int
 	readPosition int
 	ch           byte
+	eof          bool
+
+	text []byte // bytes read since the current token's leading trivia, starting at offset base
+	base int
+
+	line   int
+	column int
+
+	keepTrivia  bool
+	comments    []token.Trivia
+	diagnostics []diagnostic.Diagnostic
 }
 
 func New(input string) *Tokenizer {
-	tkzr := &Tokenizer{input: input}
+	return NewFile("", input)
+}
+
+func NewFile(filename string, input string) *Tokenizer {
+	return NewReader(filename, strings.NewReader(input))
+}
+
+func NewReader(filename string, r io.Reader) *Tokenizer {
+	tkzr := &Tokenizer{filename: filename, reader: bufio.NewReader(r), line: 1}
 	tkzr.readChar()
 	return tkzr
 }
 
 func (tkzr *Tokenizer) readChar() {
-	if tkzr.readPosition >= len(tkzr.input) {
-		tkzr.ch = 0
-	} else {
-		tkzr.ch = tkzr.input[tkzr.readPosition]
+	if tkzr.eof {
+		return
+	}
+	if tkzr.ch == '\n' {
+		tkzr.line++
+		tkzr.column = 0
 	}
+	ch, err := tkzr.reader.ReadByte()
+	tkzr.ch = ch
+	tkzr.eof = err != nil
 	tkzr.position = tkzr.readPosition
 	tkzr.readPosition += 1
+	tkzr.column++
+	if !tkzr.eof {
+		tkzr.text = append(tkzr.text, ch)
+	}
+	if err != nil && err != io.EOF {
+		tkzr.report(diagnostic.Diagnostic{
+			Message: fmt.Sprintf("Error reading input: %v", err),
+			Span:    token.Span{Start: tkzr.pos(), End: tkzr.pos()},
+		})
+	}
+}
+
+func (tkzr *Tokenizer) peek(n int) []byte {
+	b, _ := tkzr.reader.Peek(n)
+	return b
+}
+
+func (tkzr *Tokenizer) peekChar() byte {
+	b := tkzr.peek(1)
+	if len(b) == 0 {
+		return 0
+	}
+	return b[0]
+}
+
+func (tkzr *Tokenizer) slice(start int, end int) string {
+	return string(tkzr.text[start-tkzr.base : end-tkzr.base])
+}
+
+func (tkzr *Tokenizer) resetText() {
+	if tkzr.eof {
+		tkzr.text = tkzr.text[:0]
+	} else {
+		tkzr.text = append(tkzr.text[:0], tkzr.ch)
+	}
+	tkzr.base = tkzr.position
+}
+
+func (tkzr *Tokenizer) Diagnostics() []diagnostic.Diagnostic {
+	return tkzr.diagnostics
+}
+
+func (tkzr *Tokenizer) report(d diagnostic.Diagnostic) {
+	d.Severity = diagnostic.ERROR
+	tkzr.diagnostics = append(tkzr.diagnostics, d)
+}
+
+func (tkzr *Tokenizer) Comments() []token.Trivia {
+	return tkzr.comments
+}
+
+func (tkzr *Tokenizer) KeepTrivia() {
+	tkzr.keepTrivia = true
+}
+
+func (tkzr *Tokenizer) pos() token.Position {
+	return token.Position{
+		Filename: tkzr.filename,
+		Offset:   tkzr.position,
+		Line:     tkzr.line,
+		Column:   tkzr.column,
+	}
 }
 
 func (tkzr *Tokenizer) Advance() token.Token {
-	var out token.Token
+	tkzr.resetText()
+	leading := tkzr.readTrivia(false)
 
-	tkzr.ignoreWithSpace()
+	start := tkzr.pos()
+	out := tkzr.readToken()
+	out.Start = start
+	out.End = tkzr.pos()
+	out.Raw = tkzr.slice(start.Offset, out.End.Offset)
+
+	if out.Type == token.EOF {
+		return tkzr.withTrivia(out, leading, nil)
+	}
+	return tkzr.withTrivia(out, leading, tkzr.readTrivia(true))
+}
+
+func (tkzr *Tokenizer) withTrivia(tk token.Token, leading []token.Trivia, trailing []token.Trivia) token.Token {
+	if tkzr.keepTrivia {
+		tk.LeadingTrivia = leading
+		tk.TrailingTrivia = trailing
+	}
+	return tk
+}
+
+func (tkzr *Tokenizer) readToken() token.Token {
+	var out token.Token
+	if tkzr.eof {
+		out.Type = token.EOF
+		return out
+	}
 
 	switch tkzr.ch {
 	case '=':
@@ -72,25 +195,43 @@ func (tkzr *Tokenizer) Advance() token.Token {
 	case '~':
 		out = newToken(token.NOT, tkzr.ch)
 	case '"':
+		start := tkzr.pos()
 		tkzr.readChar()
-		out.Literal = tkzr.readString()
-		out.Type = token.QUOT
-		tkzr.readChar()
+		out.Literal = tkzr.readString(start)
+		out.Type = token.STRING_CONST
+		if tkzr.ch == '"' {
+			tkzr.readChar()
+		}
 		return out
-	case 0:
-		out.Literal = ""
-		out.Type = token.EOF
 	default:
 		if isLetter(tkzr.ch) {
 			out.Literal = tkzr.readIdentifier()
 			out.Type = token.LookupIdent(out.Literal)
 			return out
 		} else if isDigit(tkzr.ch) {
-			out.Type = token.INT
+			start := tkzr.pos()
+			out.Type = token.INT_CONST
 			out.Literal = tkzr.readNumber()
+			if val, err := strconv.Atoi(out.Literal); err != nil || val > maxInt {
+				tkzr.report(diagnostic.Diagnostic{
+					Message:    fmt.Sprintf("Integer constant %s is too large", out.Literal),
+					Expected:   fmt.Sprintf("integer constant between 0 and %d", maxInt),
+					Got:        out.Literal,
+					Suggestion: "compute larger values at runtime, e.g. with Math.multiply",
+					Span:       token.Span{Start: start, End: tkzr.pos()},
+				})
+			}
 			return out
 		} else {
-			out = newToken(token.ILLEGAL, tkzr.ch)
+			start := tkzr.pos()
+			out.Type = token.ILLEGAL
+			out.Literal = tkzr.readIllegal()
+			tkzr.report(diagnostic.Diagnostic{
+				Message:    fmt.Sprintf("Illegal character %q", out.Literal),
+				Suggestion: illegalSuggestion(out.Literal[0]),
+				Span:       token.Span{Start: start, End: tkzr.pos()},
+			})
+			return out
 		}
 	}
 
@@ -98,22 +239,117 @@ func (tkzr *Tokenizer) Advance() token.Token {
 	return out
 }
 
-func (tkzr *Tokenizer) ignoreWithSpace() {
-	for tkzr.ch == ' ' || tkzr.ch == '\r' || tkzr.ch == '\t' || tkzr.ch == '\n' {
+func (tkzr *Tokenizer) readTrivia(trailing bool) []token.Trivia {
+	var trivia []token.Trivia
+
+	for {
+		start := tkzr.pos()
+		var kind token.TriviaKind
+		newLine := false
+
+		switch {
+		case isWhiteSpace(tkzr.ch):
+			kind = token.WHITESPACE
+			newLine = tkzr.skipWhiteSpace(trailing)
+		case tkzr.ch == '/' && tkzr.peekChar() == '/':
+			kind = token.LINE_COMMENT
+			tkzr.skipLineComment()
+		case tkzr.ch == '/' && tkzr.peekChar() == '*':
+			kind = token.BLOCK_COMMENT
+			rest := tkzr.peek(3)
+			if len(rest) >= 2 && rest[1] == '*' && (len(rest) < 3 || rest[2] != '/') {
+				kind = token.DOC_COMMENT
+			}
+			tkzr.skipBlockComment()
+		default:
+			return trivia
+		}
+
+		end := tkzr.pos()
+		tr := token.Trivia{
+			Kind:     kind,
+			Text:     tkzr.slice(start.Offset, end.Offset),
+			Trailing: trailing,
+			Span:     token.Span{Start: start, End: end},
+		}
+		trivia = append(trivia, tr)
+		if kind != token.WHITESPACE {
+			tkzr.comments = append(tkzr.comments, tr)
+		}
+		if trailing && newLine {
+			return trivia
+		}
+	}
+}
+
+func (tkzr *Tokenizer) skipWhiteSpace(stopAfterNewLine bool) bool {
+	for isWhiteSpace(tkzr.ch) {
+		newLine := tkzr.ch == '\n'
+		tkzr.readChar()
+		if newLine && stopAfterNewLine {
+			return true
+		}
+	}
+	return false
+}
+
+func (tkzr *Tokenizer) skipLineComment() {
+	for tkzr.ch != '\n' && !tkzr.eof {
 		tkzr.readChar()
 	}
 }
 
+func (tkzr *Tokenizer) skipBlockComment() {
+	start := tkzr.pos()
+	tkzr.readChar()
+	tkzr.readChar()
+	for !(tkzr.ch == '*' && tkzr.peekChar() == '/') {
+		if tkzr.eof {
+			tkzr.report(diagnostic.Diagnostic{
+				Message:    "Unterminated block comment",
+				Expected:   "'*/'",
+				Got:        "end of file",
+				Suggestion: "close the comment with '*/'",
+				Span:       token.Span{Start: start, End: tkzr.pos()},
+			})
+			return
+		}
+		tkzr.readChar()
+	}
+	tkzr.readChar()
+	tkzr.readChar()
+}
+
 func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func (tkzr *Tokenizer) readString() string {
+func (tkzr *Tokenizer) readString(start token.Position) string {
 	position := tkzr.position
 	for tkzr.ch != '"' {
+		switch {
+		case tkzr.eof:
+			tkzr.report(diagnostic.Diagnostic{
+				Message:    "Unterminated string constant",
+				Expected:   `'"'`,
+				Got:        "end of file",
+				Suggestion: `close the string with '"'`,
+				Span:       token.Span{Start: start, End: tkzr.pos()},
+			})
+			return tkzr.slice(position, tkzr.position)
+		case tkzr.ch == '\r' || tkzr.ch == '\n':
+			tkzr.report(diagnostic.Diagnostic{
+				Message:    "Newline in string constant",
+				Expected:   `'"'`,
+				Got:        "newline",
+				Suggestion: `string constants must end on the line they start, close it with '"'`,
+				Span:       token.Span{Start: start, End: tkzr.pos()},
+			})
+			return tkzr.slice(position, tkzr.position)
+		}
 		tkzr.readChar()
 	}
-	return tkzr.input[position:tkzr.position]
+	return tkzr.slice(position, tkzr.position)
 }
 
 func (tkzr *Tokenizer) readIdentifier() string {
@@ -121,7 +357,17 @@ func (tkzr *Tokenizer) readIdentifier() string {
 	for isLetter(tkzr.ch) || isDigit(tkzr.ch) {
 		tkzr.readChar()
 	}
-	return tkzr.input[position:tkzr.position]
+	return tkzr.slice(position, tkzr.position)
+}
+
+// readIllegal reads a whole UTF-8 encoded character, or a single byte of invalid input
+func (tkzr *Tokenizer) readIllegal() string {
+	position := tkzr.position
+	_, size := utf8.DecodeRune(append([]byte{tkzr.ch}, tkzr.peek(utf8.UTFMax-1)...))
+	for i := 0; i < size; i++ {
+		tkzr.readChar()
+	}
+	return tkzr.slice(position, tkzr.position)
 }
 
 func (tkzr *Tokenizer) readNumber() string {
@@ -129,7 +375,24 @@ func (tkzr *Tokenizer) readNumber() string {
 	for isDigit(tkzr.ch) {
 		tkzr.readChar()
 	}
-	return tkzr.input[position:tkzr.position]
+	return tkzr.slice(position, tkzr.position)
+}
+
+func illegalSuggestion(ch byte) string {
+	switch ch {
+	case '\'':
+		return "Jack has no character literals, use a string constant or the character code"
+	case '!':
+		return "use '~' for logical not"
+	case '%':
+		return "Jack has no modulo operator, use Math.divide and Math.multiply"
+	default:
+		return "remove the character, Jack source only allows letters, digits, whitespace and the symbols {}()[].,;+-*/&|<>=~"
+	}
+}
+
+func isWhiteSpace(ch byte) bool {
+	return ch == ' ' || ch == '\r' || ch == '\t' || ch == '\n'
 }
 
 func isLetter(ch byte) bool {
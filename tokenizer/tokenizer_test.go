@@ -1,7 +1,11 @@
 package tokenizer
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/tivt2/jack-compiler/token"
 )
@@ -90,7 +94,7 @@ func TestAdvance(t *testing.T) {
 		{token.ELSE, "else"},
 		{token.WHILE, "while"},
 
-		{token.QUOT, "testing"},
+		{token.STRING_CONST, "testing"},
 		{token.RBRACE, "}"},
 	}
 
@@ -108,3 +112,337 @@ func TestAdvance(t *testing.T) {
 		}
 	}
 }
+
+func TestAdvancePosition(t *testing.T) {
+	input := "class Main {\n  let s = \"hi\";\n}"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedStart   token.Position
+		expectedEnd     token.Position
+	}{
+		{"class", token.Position{Filename: "Main.jack", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "Main.jack", Offset: 5, Line: 1, Column: 6}},
+		{"Main", token.Position{Filename: "Main.jack", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "Main.jack", Offset: 10, Line: 1, Column: 11}},
+		{"{", token.Position{Filename: "Main.jack", Offset: 11, Line: 1, Column: 12}, token.Position{Filename: "Main.jack", Offset: 12, Line: 1, Column: 13}},
+		{"let", token.Position{Filename: "Main.jack", Offset: 15, Line: 2, Column: 3}, token.Position{Filename: "Main.jack", Offset: 18, Line: 2, Column: 6}},
+		{"s", token.Position{Filename: "Main.jack", Offset: 19, Line: 2, Column: 7}, token.Position{Filename: "Main.jack", Offset: 20, Line: 2, Column: 8}},
+		{"=", token.Position{Filename: "Main.jack", Offset: 21, Line: 2, Column: 9}, token.Position{Filename: "Main.jack", Offset: 22, Line: 2, Column: 10}},
+		{"hi", token.Position{Filename: "Main.jack", Offset: 23, Line: 2, Column: 11}, token.Position{Filename: "Main.jack", Offset: 27, Line: 2, Column: 15}},
+		{";", token.Position{Filename: "Main.jack", Offset: 27, Line: 2, Column: 15}, token.Position{Filename: "Main.jack", Offset: 28, Line: 2, Column: 16}},
+		{"}", token.Position{Filename: "Main.jack", Offset: 29, Line: 3, Column: 1}, token.Position{Filename: "Main.jack", Offset: 30, Line: 3, Column: 2}},
+		{"", token.Position{Filename: "Main.jack", Offset: 30, Line: 3, Column: 2}, token.Position{Filename: "Main.jack", Offset: 30, Line: 3, Column: 2}},
+	}
+
+	tkzr := NewFile("Main.jack", input)
+
+	for i, test := range tests {
+		tk := tkzr.Advance()
+
+		if test.expectedLiteral != tk.Literal {
+			t.Fatalf("Literal failed. test index %d, expected: %q, received: %q", i, test.expectedLiteral, tk.Literal)
+		}
+
+		if test.expectedStart != tk.Start {
+			t.Fatalf("Start failed. test index %d, expected: %v, received: %v", i, test.expectedStart, tk.Start)
+		}
+
+		if test.expectedEnd != tk.End {
+			t.Fatalf("End failed. test index %d, expected: %v, received: %v", i, test.expectedEnd, tk.End)
+		}
+	}
+}
+
+func TestAdvanceComments(t *testing.T) {
+	input := `// line comment
+	/* block comment */
+	/** doc
+	 * comment */
+	let s = "http://x /* not a comment */"; // trailing
+	let d = a / b; /* a
+	multi line */ return;
+	`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.LET, "let", 5},
+		{token.IDENT, "s", 5},
+		{token.ASSIGN, "=", 5},
+		{token.STRING_CONST, "http://x /* not a comment */", 5},
+		{token.SEMICOLON, ";", 5},
+		{token.LET, "let", 6},
+		{token.IDENT, "d", 6},
+		{token.ASSIGN, "=", 6},
+		{token.IDENT, "a", 6},
+		{token.FSLASH, "/", 6},
+		{token.IDENT, "b", 6},
+		{token.SEMICOLON, ";", 6},
+		{token.RETURN, "return", 7},
+		{token.SEMICOLON, ";", 7},
+		{token.EOF, "", 8},
+	}
+
+	tkzr := New(input)
+
+	for i, test := range tests {
+		tk := tkzr.Advance()
+
+		if test.expectedType != tk.Type {
+			t.Fatalf("TokenType failed. test index %d, expected: %q, received: %q", i, test.expectedType, tk.Type)
+		}
+
+		if test.expectedLiteral != tk.Literal {
+			t.Fatalf("Literal failed. test index %d, expected: %q, received: %q", i, test.expectedLiteral, tk.Literal)
+		}
+
+		if test.expectedLine != tk.Start.Line {
+			t.Fatalf("Line failed. test index %d, expected: %d, received: %d", i, test.expectedLine, tk.Start.Line)
+		}
+	}
+
+	if len(tkzr.Diagnostics()) != 0 {
+		t.Fatalf("Diagnostics failed, expected none, received: %v", tkzr.Diagnostics())
+	}
+}
+
+func TestAdvanceUnterminatedComment(t *testing.T) {
+	tkzr := New("let x = 1;\n/* never closed\n let y = 2;")
+
+	for tk := tkzr.Advance(); tk.Type != token.EOF; tk = tkzr.Advance() {
+	}
+
+	diags := tkzr.Diagnostics()
+	expected := "2:1: error: Unterminated block comment, expected '*/', got end of file (hint: close the comment with '*/')"
+	if len(diags) != 1 || diags[0].String() != expected {
+		t.Fatalf("Diagnostics failed, expected: %s, received: %v", expected, diags)
+	}
+}
+
+func TestAdvanceLexicalErrors(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedTypes    []token.TokenType
+		expectedMessages []string
+	}{
+		{
+			`let s = "never closed`,
+			[]token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.STRING_CONST, token.EOF},
+			[]string{`1:9: error: Unterminated string constant, expected '"', got end of file (hint: close the string with '"')`},
+		},
+		{
+			"let s = \"broken\nline\";",
+			[]token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.STRING_CONST, token.IDENT, token.STRING_CONST, token.EOF},
+			[]string{
+				`1:9: error: Newline in string constant, expected '"', got newline (hint: string constants must end on the line they start, close it with '"')`,
+				`2:5: error: Unterminated string constant, expected '"', got end of file (hint: close the string with '"')`,
+			},
+		},
+		{
+			"let c = 'a';",
+			[]token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.ILLEGAL, token.IDENT, token.ILLEGAL, token.SEMICOLON, token.EOF},
+			[]string{
+				`1:9: error: Illegal character "'" (hint: Jack has no character literals, use a string constant or the character code)`,
+				`1:11: error: Illegal character "'" (hint: Jack has no character literals, use a string constant or the character code)`,
+			},
+		},
+		{
+			"if (!x) {}",
+			[]token.TokenType{token.IF, token.LPAREN, token.ILLEGAL, token.IDENT, token.RPAREN, token.LBRACE, token.RBRACE, token.EOF},
+			[]string{`1:5: error: Illegal character "!" (hint: use '~' for logical not)`},
+		},
+		{
+			"let x = 32767; let y = 32768;",
+			[]token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT_CONST, token.SEMICOLON, token.LET, token.IDENT, token.ASSIGN, token.INT_CONST, token.SEMICOLON, token.EOF},
+			[]string{`1:24: error: Integer constant 32768 is too large, expected integer constant between 0 and 32767, got 32768 (hint: compute larger values at runtime, e.g. with Math.multiply)`},
+		},
+		{
+			"var int é;",
+			[]token.TokenType{token.VAR, token.INT, token.ILLEGAL, token.SEMICOLON, token.EOF},
+			[]string{`1:9: error: Illegal character "é" (hint: remove the character, Jack source only allows letters, digits, whitespace and the symbols {}()[].,;+-*/&|<>=~)`},
+		},
+		{
+			"let x\xff;",
+			[]token.TokenType{token.LET, token.IDENT, token.ILLEGAL, token.SEMICOLON, token.EOF},
+			[]string{`1:6: error: Illegal character "\xff" (hint: remove the character, Jack source only allows letters, digits, whitespace and the symbols {}()[].,;+-*/&|<>=~)`},
+		},
+		{
+			"function void f() {\x00 return; }",
+			[]token.TokenType{token.FUNCTION, token.VOID, token.IDENT, token.LPAREN, token.RPAREN, token.LBRACE, token.ILLEGAL, token.RETURN, token.SEMICOLON, token.RBRACE, token.EOF},
+			[]string{`1:20: error: Illegal character "\x00" (hint: remove the character, Jack source only allows letters, digits, whitespace and the symbols {}()[].,;+-*/&|<>=~)`},
+		},
+	}
+
+	for i, test := range tests {
+		tkzr := New(test.input)
+
+		var types []token.TokenType
+		for {
+			tk := tkzr.Advance()
+			types = append(types, tk.Type)
+			if tk.Type == token.EOF {
+				break
+			}
+		}
+
+		if len(types) != len(test.expectedTypes) {
+			t.Fatalf("Tokens failed. test index %d, expected: %v, received: %v", i, test.expectedTypes, types)
+		}
+		for j := range types {
+			if types[j] != test.expectedTypes[j] {
+				t.Fatalf("Tokens failed. test index %d, expected: %v, received: %v", i, test.expectedTypes, types)
+			}
+		}
+
+		diags := tkzr.Diagnostics()
+		if len(diags) != len(test.expectedMessages) {
+			t.Fatalf("Diagnostics failed. test index %d, expected: %v, received: %v", i, test.expectedMessages, diags)
+		}
+		for j, d := range diags {
+			if d.String() != test.expectedMessages[j] {
+				t.Fatalf("Diagnostics failed. test index %d, expected: %s, received: %s", i, test.expectedMessages[j], d.String())
+			}
+		}
+	}
+}
+
+func TestAdvanceTrivia(t *testing.T) {
+	input := "/** Main. */\nclass Main { // entry\n\tfield int x; /* a\n b */ \r\n\n\tfunction void main() {}\n}\n// end\n"
+
+	tkzr := New(input)
+	tkzr.KeepTrivia()
+
+	var tokens []token.Token
+	for {
+		tk := tkzr.Advance()
+		tokens = append(tokens, tk)
+		if tk.Type == token.EOF {
+			break
+		}
+	}
+
+	var out string
+	for _, tk := range tokens {
+		out += tk.FullText()
+	}
+	if out != input {
+		t.Fatalf("FullText failed, expected: %q, received: %q", input, out)
+	}
+
+	tests := []struct {
+		index            int
+		expectedLeading  []token.TriviaKind
+		expectedTrailing []token.TriviaKind
+	}{
+		{0, []token.TriviaKind{token.DOC_COMMENT, token.WHITESPACE}, []token.TriviaKind{token.WHITESPACE}},
+		{2, nil, []token.TriviaKind{token.WHITESPACE, token.LINE_COMMENT, token.WHITESPACE}},
+		{3, []token.TriviaKind{token.WHITESPACE}, []token.TriviaKind{token.WHITESPACE}},
+		{6, nil, []token.TriviaKind{token.WHITESPACE, token.BLOCK_COMMENT, token.WHITESPACE}},
+		{7, []token.TriviaKind{token.WHITESPACE}, []token.TriviaKind{token.WHITESPACE}},
+		{len(tokens) - 1, []token.TriviaKind{token.LINE_COMMENT, token.WHITESPACE}, nil},
+	}
+
+	for _, test := range tests {
+		tk := tokens[test.index]
+		if !sameKinds(tk.LeadingTrivia, test.expectedLeading) {
+			t.Fatalf("LeadingTrivia failed. token %q, expected: %v, received: %v", tk.Literal, test.expectedLeading, tk.LeadingTrivia)
+		}
+		if !sameKinds(tk.TrailingTrivia, test.expectedTrailing) {
+			t.Fatalf("TrailingTrivia failed. token %q, expected: %v, received: %v", tk.Literal, test.expectedTrailing, tk.TrailingTrivia)
+		}
+	}
+}
+
+func sameKinds(trivia []token.Trivia, kinds []token.TriviaKind) bool {
+	if len(trivia) != len(kinds) {
+		return false
+	}
+	for i, tr := range trivia {
+		if tr.Kind != kinds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAdvanceCategory(t *testing.T) {
+	input := `int 42 "int" integer ; while`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedCategory token.Category
+		expectedDescribe string
+	}{
+		{token.INT, token.KEYWORD, "keyword 'int'"},
+		{token.INT_CONST, token.INTEGER, "integer constant 42"},
+		{token.STRING_CONST, token.STRING, `string constant "int"`},
+		{token.IDENT, token.IDENTIFIER, "identifier 'integer'"},
+		{token.SEMICOLON, token.SYMBOL, "symbol ';'"},
+		{token.WHILE, token.KEYWORD, "keyword 'while'"},
+		{token.EOF, "", "end of file"},
+	}
+
+	tkzr := New(input)
+
+	for i, test := range tests {
+		tk := tkzr.Advance()
+
+		if test.expectedType != tk.Type {
+			t.Fatalf("TokenType failed. test index %d, expected: %q, received: %q", i, test.expectedType, tk.Type)
+		}
+
+		if test.expectedCategory != tk.Category() {
+			t.Fatalf("Category failed. test index %d, expected: %q, received: %q", i, test.expectedCategory, tk.Category())
+		}
+
+		if test.expectedDescribe != tk.Describe() {
+			t.Fatalf("Describe failed. test index %d, expected: %q, received: %q", i, test.expectedDescribe, tk.Describe())
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := "/** Main. */\nclass Main {\n\tfunction void main() { // entry\n\t\tdo Output.printString(\"hi /* there */\");\n\t\treturn 32767; /**/\n\t}\n}\n"
+
+	expected := New(input)
+	expected.KeepTrivia()
+	received := NewReader("", iotest.OneByteReader(strings.NewReader(input)))
+	received.KeepTrivia()
+
+	var full strings.Builder
+	for i := 0; ; i++ {
+		exp := expected.Advance()
+		rec := received.Advance()
+
+		if exp.Type != rec.Type || exp.Literal != rec.Literal || exp.Raw != rec.Raw || exp.Span != rec.Span {
+			t.Fatalf("Token failed. index %d, expected: %+v, received: %+v", i, exp, rec)
+		}
+		if !reflect.DeepEqual(exp.LeadingTrivia, rec.LeadingTrivia) || !reflect.DeepEqual(exp.TrailingTrivia, rec.TrailingTrivia) {
+			t.Fatalf("Trivia failed. index %d, expected: %+v, received: %+v", i, exp, rec)
+		}
+
+		full.WriteString(rec.FullText())
+		if rec.Type == token.EOF {
+			break
+		}
+	}
+
+	if full.String() != input {
+		t.Fatalf("FullText failed, expected: %q, received: %q", input, full.String())
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	tkzr := NewReader("Main.jack", iotest.DataErrReader(iotest.ErrReader(errors.New("disk failure"))))
+
+	if tk := tkzr.Advance(); tk.Type != token.EOF {
+		t.Fatalf("Advance failed, expected: %s, received: %s", token.EOF, tk.Type)
+	}
+
+	diags := tkzr.Diagnostics()
+	expected := "Main.jack:1:1: error: Error reading input: disk failure"
+	if len(diags) != 1 || diags[0].String() != expected {
+		t.Fatalf("Diagnostics failed, expected: %s, received: %v", expected, diags)
+	}
+}
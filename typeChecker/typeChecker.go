@@ -0,0 +1,261 @@
+package typeChecker
+
+import (
+	"fmt"
+
+	"github.com/tivt2/jack-compiler/classIndex"
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/symbolTable"
+	"github.com/tivt2/jack-compiler/token"
+)
+
+type Strictness int
+
+const (
+	LENIENT Strictness = iota
+	STRICT
+)
+
+const (
+	INT     = "int"
+	CHAR    = "char"
+	BOOLEAN = "boolean"
+	VOID    = "void"
+	STRING  = "String"
+	ARRAY   = "Array"
+	NULL    = "null"
+	UNKNOWN = ""
+)
+
+type Config struct {
+	Strictness Strictness
+	StrictChar bool // int and char are not interchangeable
+}
+
+type TypeChecker struct {
+	c      *parseTree.Class
+	s      *symbolTable.SymbolTable
+	idx    *classIndex.Index
+	config Config
+
+	current *parseTree.SubroutineDec
+
+	diagnostics []diagnostic.Diagnostic
+}
+
+func New(c *parseTree.Class, idx *classIndex.Index, config Config) *TypeChecker {
+	return &TypeChecker{
+		c:      c,
+		s:      symbolTable.New(),
+		idx:    idx,
+		config: config,
+	}
+}
+
+func Check(c *parseTree.Class, idx *classIndex.Index, config Config) []diagnostic.Diagnostic {
+	return New(c, idx, config).Check()
+}
+
+func (tc *TypeChecker) Check() []diagnostic.Diagnostic {
+	tc.s.DefineClass(tc.c)
+	for _, sd := range tc.c.Subroutines() {
+		tc.checkSubroutineDec(sd)
+	}
+	return tc.diagnostics
+}
+
+func (tc *TypeChecker) checkSubroutineDec(sd *parseTree.SubroutineDec) {
+	tc.current = sd
+	tc.s.DefineSubroutine(tc.c, sd)
+
+	tc.checkStatements(sd.SubroutineBody.Statements)
+}
+
+func (tc *TypeChecker) checkStatements(stmts []parseTree.Statement) {
+	for _, stmt := range stmts {
+		tc.checkStatement(stmt)
+	}
+}
+
+func (tc *TypeChecker) checkStatement(stmt parseTree.Statement) {
+	switch stmt := stmt.(type) {
+	case *parseTree.LetStatement:
+		target := tc.typeOf(stmt.Ident)
+		value := tc.typeOf(stmt.Expression)
+		if !tc.assignable(target, value) {
+			tc.mismatch(stmt.Expression.Range(), fmt.Sprintf("Type mismatch in assignment to '%s'", stmt.Ident.Value), target, value)
+		}
+	case *parseTree.ReturnStatement:
+		if stmt.Expression == nil {
+			return
+		}
+		value := tc.typeOf(stmt.Expression)
+		target := tc.current.DecType.Literal
+		if target != VOID && !tc.assignable(target, value) {
+			tc.mismatch(stmt.Expression.Range(), fmt.Sprintf("Type mismatch in return of %s.%s", tc.c.Ident.Value, tc.current.Ident.Value), target, value)
+		}
+	case *parseTree.DoStatement:
+		tc.typeOf(stmt.Expression)
+	case *parseTree.WhileStatement:
+		tc.checkCondition(stmt.Expression, "while")
+		tc.checkStatements(stmt.Stmts)
+	case *parseTree.IfStatement:
+		tc.checkCondition(stmt.Expression, "if")
+		tc.checkStatements(stmt.IfStmts)
+		tc.checkStatements(stmt.Else)
+	}
+}
+
+func (tc *TypeChecker) checkCondition(exp parseTree.Expression, stmt string) {
+	if t := tc.typeOf(exp); t != UNKNOWN && t != BOOLEAN {
+		tc.mismatch(exp.Range(), fmt.Sprintf("Condition of %s statement must be boolean", stmt), BOOLEAN, t)
+	}
+}
+
+func (tc *TypeChecker) typeOf(exp parseTree.Expression) string {
+	switch exp := exp.(type) {
+	case *parseTree.Group:
+		return tc.typeOf(exp.Expression)
+	case *parseTree.Prefix:
+		operand := tc.typeOf(exp.Expression)
+		if exp.Operator.Type == token.NOT && operand == BOOLEAN {
+			return BOOLEAN
+		}
+		tc.checkOperand(exp.Operator, exp.Expression, operand)
+		return INT
+	case *parseTree.Infix:
+		return tc.typeOfInfix(exp)
+	case *parseTree.IntegerConstant:
+		return INT
+	case *parseTree.StringConstant:
+		return STRING
+	case *parseTree.KeywordConstant:
+		switch exp.Token.Type {
+		case token.TRUE, token.FALSE:
+			return BOOLEAN
+		case token.NULL:
+			return NULL
+		case token.THIS:
+			return tc.c.Ident.Value
+		}
+	case *parseTree.Identifier:
+		decType := tc.s.TypeOf(exp.Value)
+		if exp.Indexer == nil {
+			return decType
+		}
+		if isPrimitive(decType) {
+			tc.mismatch(exp.Span, fmt.Sprintf("Cannot index '%s'", exp.Value), ARRAY, decType)
+		}
+		tc.checkOperand(token.Token{Type: token.LBRACKET, Literal: token.LBRACKET}, exp.Indexer, tc.typeOf(exp.Indexer))
+		return UNKNOWN
+	case *parseTree.SubroutineCall:
+		return tc.typeOfCall(exp)
+	}
+	return UNKNOWN
+}
+
+func (tc *TypeChecker) typeOfInfix(exp *parseTree.Infix) string {
+	left := tc.typeOf(exp.Left)
+	right := tc.typeOf(exp.Right)
+
+	switch exp.Operator.Type {
+	case token.ASSIGN:
+		if !tc.assignable(left, right) && !tc.assignable(right, left) {
+			tc.mismatch(exp.Right.Range(), fmt.Sprintf("Invalid operand for '%s'", exp.Operator.Literal), left, right)
+		}
+		return BOOLEAN
+	case token.AMP, token.BAR:
+		if left == BOOLEAN && right == BOOLEAN {
+			return BOOLEAN
+		}
+		if left == BOOLEAN || right == BOOLEAN {
+			tc.mismatch(exp.Right.Range(), fmt.Sprintf("Invalid operand for '%s'", exp.Operator.Literal), left, right)
+			return BOOLEAN
+		}
+		tc.checkOperand(exp.Operator, exp.Left, left)
+		tc.checkOperand(exp.Operator, exp.Right, right)
+		return INT
+	case token.LT, token.GT:
+		tc.checkOperand(exp.Operator, exp.Left, left)
+		tc.checkOperand(exp.Operator, exp.Right, right)
+		return BOOLEAN
+	default:
+		tc.checkOperand(exp.Operator, exp.Left, left)
+		tc.checkOperand(exp.Operator, exp.Right, right)
+		return INT
+	}
+}
+
+func (tc *TypeChecker) typeOfCall(exp *parseTree.SubroutineCall) string {
+	className := tc.c.Ident.Value
+	if exp.Ident != nil {
+		className = exp.Ident.Value
+		if decType := tc.s.TypeOf(exp.Ident.Value); decType != UNKNOWN {
+			className = decType
+		}
+	}
+
+	args := make([]string, len(exp.ExpList))
+	for i, e := range exp.ExpList {
+		args[i] = tc.typeOf(e)
+	}
+
+	sub, ok := tc.idx.Subroutine(className, exp.Subroutine.Value)
+	if !ok {
+		return UNKNOWN
+	}
+	for i, param := range sub.Params {
+		if i >= len(args) {
+			break
+		}
+		if !tc.assignable(param.DecType, args[i]) {
+			tc.mismatch(exp.ExpList[i].Range(), fmt.Sprintf("Type mismatch in argument %d of %s", i+1, sub.FullName()), param.DecType, args[i])
+		}
+	}
+	return sub.DecType
+}
+
+func (tc *TypeChecker) checkOperand(operator token.Token, exp parseTree.Expression, t string) {
+	if !tc.assignable(INT, t) {
+		tc.mismatch(exp.Range(), fmt.Sprintf("Invalid operand for '%s'", operator.Literal), INT, t)
+	}
+}
+
+func (tc *TypeChecker) assignable(target string, value string) bool {
+	switch {
+	case target == UNKNOWN || value == UNKNOWN:
+		return true
+	case target == value:
+		return true
+	case value == NULL:
+		return !isPrimitive(target) && target != VOID
+	case target == ARRAY || value == ARRAY:
+		return isObject(target) && isObject(value)
+	case target == INT && value == CHAR, target == CHAR && value == INT:
+		return !tc.config.StrictChar
+	}
+	return false
+}
+
+func isPrimitive(t string) bool {
+	return t == INT || t == CHAR || t == BOOLEAN
+}
+
+func isObject(t string) bool {
+	return !isPrimitive(t) && t != VOID
+}
+
+func (tc *TypeChecker) mismatch(span token.Span, msg string, expected string, got string) {
+	severity := diagnostic.Severity(diagnostic.WARNING)
+	if tc.config.Strictness == STRICT {
+		severity = diagnostic.ERROR
+	}
+	tc.diagnostics = append(tc.diagnostics, diagnostic.Diagnostic{
+		Severity: severity,
+		Message:  msg,
+		Expected: expected,
+		Got:      got,
+		Span:     span,
+	})
+}
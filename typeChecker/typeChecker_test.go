@@ -0,0 +1,121 @@
+package typeChecker
+
+import (
+	"testing"
+
+	"github.com/tivt2/jack-compiler/classIndex"
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+func TestCheck(t *testing.T) {
+	input := `class Main {
+		function void main() {
+			var int x;
+			var char c;
+			var boolean b;
+			var Array a;
+			let c = x;
+			let b = x;
+			let a = null;
+			let a[c] = b;
+			let x = Main.twice(b, "a");
+			if (x) { let x = -b; }
+			while (~b & (x < 3)) { let x = x + 1; }
+			return;
+		}
+		function int twice(int n, String s) {
+			return s;
+		}
+	}`
+
+	tests := []struct {
+		config   Config
+		expected []string
+	}{
+		{
+			Config{},
+			[]string{
+				"8:12: warning: Type mismatch in assignment to 'b', expected boolean, got int",
+				"11:23: warning: Type mismatch in argument 1 of Main.twice, expected int, got boolean",
+				"12:8: warning: Condition of if statement must be boolean, expected boolean, got int",
+				"12:22: warning: Invalid operand for '-', expected int, got boolean",
+				"17:11: warning: Type mismatch in return of Main.twice, expected int, got String",
+			},
+		},
+		{
+			Config{Strictness: STRICT, StrictChar: true},
+			[]string{
+				"7:12: error: Type mismatch in assignment to 'c', expected char, got int",
+				"8:12: error: Type mismatch in assignment to 'b', expected boolean, got int",
+				"10:10: error: Invalid operand for '[', expected int, got char",
+				"11:23: error: Type mismatch in argument 1 of Main.twice, expected int, got boolean",
+				"12:8: error: Condition of if statement must be boolean, expected boolean, got int",
+				"12:22: error: Invalid operand for '-', expected int, got boolean",
+				"17:11: error: Type mismatch in return of Main.twice, expected int, got String",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		tkzr := tokenizer.New(input)
+		p := parser.New(tkzr)
+		class, diags := p.ParseClass()
+		if len(diags) != 0 {
+			t.Fatalf("ParseClass() test index %d, unexpected diagnostics: %v", i, diags)
+		}
+
+		idx := classIndex.New()
+		idx.Add(class)
+		diags = Check(class, idx, test.config)
+
+		if len(diags) != len(test.expected) {
+			t.Fatalf("Check() test index %d, expected %d diagnostics, received: %v", i, len(test.expected), diags)
+		}
+		for j, d := range diags {
+			if d.String() != test.expected[j] {
+				t.Fatalf("Check() test index %d, expected: %s, received: %s", i, test.expected[j], d.String())
+			}
+		}
+	}
+}
+
+func TestCheckBuiltins(t *testing.T) {
+	input := `class Main {
+		method void dispose() {
+			var String s;
+			var Array a;
+			let a = Memory.alloc(2);
+			let s = Keyboard.readLine("name?");
+			do Output.printString(s.length());
+			do Screen.setColor(1);
+			do Memory.deAlloc(this);
+			return;
+		}
+	}`
+
+	expected := []string{
+		"7:26: warning: Type mismatch in argument 1 of Output.printString, expected String, got int",
+		"8:23: warning: Type mismatch in argument 1 of Screen.setColor, expected boolean, got int",
+	}
+
+	tkzr := tokenizer.New(input)
+	p := parser.New(tkzr)
+	class, diags := p.ParseClass()
+	if len(diags) != 0 {
+		t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+	}
+
+	idx := classIndex.New()
+	idx.Add(class)
+	diags = Check(class, idx, Config{})
+
+	if len(diags) != len(expected) {
+		t.Fatalf("Check() expected %d diagnostics, received: %v", len(expected), diags)
+	}
+	for i, d := range diags {
+		if d.String() != expected[i] {
+			t.Fatalf("Check() expected: %s, received: %s", expected[i], d.String())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package usageAnalyzer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tivt2/jack-compiler/diagnostic"
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/symbolTable"
+)
+
+const Annotation = "jack:allow unused"
+
+type Analyzer struct {
+	c *parseTree.Class
+	s *symbolTable.SymbolTable
+
+	diagnostics []diagnostic.Diagnostic
+}
+
+func New(c *parseTree.Class) *Analyzer {
+	return &Analyzer{c: c, s: symbolTable.New()}
+}
+
+func Analyze(c *parseTree.Class) []diagnostic.Diagnostic {
+	return New(c).Analyze()
+}
+
+func (a *Analyzer) Analyze() []diagnostic.Diagnostic {
+	a.s.DefineClass(a.c)
+	for _, sd := range a.c.Subroutines() {
+		a.analyzeSubroutineDec(sd)
+	}
+
+	for _, sym := range a.s.ClassSymbols() {
+		switch {
+		case sym.Reads == 0 && sym.Writes == 0:
+			a.report(sym, fmt.Sprintf("%s '%s' is never used", title(sym.Kind), sym.Name))
+		case sym.Reads == 0 && sym.Kind == "field":
+			a.report(sym, fmt.Sprintf("Field '%s' is written but never read", sym.Name))
+		}
+	}
+
+	sort.SliceStable(a.diagnostics, func(i, j int) bool {
+		return a.diagnostics[i].Start.Offset < a.diagnostics[j].Start.Offset
+	})
+	return a.diagnostics
+}
+
+func (a *Analyzer) analyzeSubroutineDec(sd *parseTree.SubroutineDec) {
+	a.s.DefineSubroutine(a.c, sd)
+
+	a.analyzeStatements(sd.SubroutineBody.Statements)
+
+	for _, sym := range a.s.SubroutineSymbols() {
+		if sym.Reads > 0 || sym.Name == "this" {
+			continue
+		}
+		switch {
+		case sym.Kind == "argument":
+			a.report(sym, fmt.Sprintf("Parameter '%s' is never used", sym.Name))
+		case sym.Writes == 0:
+			a.report(sym, fmt.Sprintf("Local variable '%s' is never used", sym.Name))
+		default:
+			a.report(sym, fmt.Sprintf("Local variable '%s' is assigned but never read", sym.Name))
+		}
+	}
+}
+
+func (a *Analyzer) analyzeStatements(stmts []parseTree.Statement) {
+	for _, stmt := range stmts {
+		a.analyzeStatement(stmt)
+	}
+}
+
+func (a *Analyzer) analyzeStatement(stmt parseTree.Statement) {
+	switch stmt := stmt.(type) {
+	case *parseTree.LetStatement:
+		if stmt.Ident.Indexer == nil {
+			a.s.Write(stmt.Ident.Value)
+		} else {
+			a.analyzeExpression(stmt.Ident)
+		}
+		a.analyzeExpression(stmt.Expression)
+	case *parseTree.ReturnStatement:
+		if stmt.Expression != nil {
+			a.analyzeExpression(stmt.Expression)
+		}
+	case *parseTree.DoStatement:
+		a.analyzeExpression(stmt.Expression)
+	case *parseTree.WhileStatement:
+		a.analyzeExpression(stmt.Expression)
+		a.analyzeStatements(stmt.Stmts)
+	case *parseTree.IfStatement:
+		a.analyzeExpression(stmt.Expression)
+		a.analyzeStatements(stmt.IfStmts)
+		a.analyzeStatements(stmt.Else)
+	}
+}
+
+func (a *Analyzer) analyzeExpression(exp parseTree.Expression) {
+	switch exp := exp.(type) {
+	case *parseTree.Prefix:
+		a.analyzeExpression(exp.Expression)
+	case *parseTree.Group:
+		a.analyzeExpression(exp.Expression)
+	case *parseTree.Infix:
+		a.analyzeExpression(exp.Left)
+		a.analyzeExpression(exp.Right)
+	case *parseTree.Identifier:
+		a.s.Read(exp.Value)
+		if exp.Indexer != nil {
+			a.analyzeExpression(exp.Indexer)
+		}
+	case *parseTree.SubroutineCall:
+		if exp.Ident != nil {
+			a.s.Read(exp.Ident.Value)
+		}
+		for _, e := range exp.ExpList {
+			a.analyzeExpression(e)
+		}
+	}
+}
+
+func (a *Analyzer) report(sym symbolTable.Symbol, msg string) {
+	if a.c.Annotated(sym.Start.Line, Annotation) {
+		return
+	}
+	a.diagnostics = append(a.diagnostics, diagnostic.Diagnostic{
+		Severity:   diagnostic.WARNING,
+		Message:    msg,
+		Suggestion: fmt.Sprintf("remove it or add a '// %s' comment", Annotation),
+		Span:       sym.Span,
+	})
+}
+
+func title(kind string) string {
+	switch kind {
+	case "field":
+		return "Field"
+	case "static":
+		return "Static"
+	}
+	return kind
+}
@@ -0,0 +1,53 @@
+package usageAnalyzer
+
+import (
+	"testing"
+
+	"github.com/tivt2/jack-compiler/parser"
+	"github.com/tivt2/jack-compiler/tokenizer"
+)
+
+func TestAnalyze(t *testing.T) {
+	input := `class Main {
+	field int x, y, z;
+	static int count, total;
+	static boolean debug; // jack:allow unused
+
+	method int get(int a, int b, Array c) {
+		var int i, j, k;
+		// jack:allow unused
+		var int l;
+		let x = a;
+		let z = z + 1;
+		let total = 0;
+		let j = 1;
+		let c[i] = y;
+		return k;
+	}
+}`
+
+	expected := []string{
+		"2:12: warning: Field 'x' is written but never read (hint: remove it or add a '// jack:allow unused' comment)",
+		"3:13: warning: Static 'count' is never used (hint: remove it or add a '// jack:allow unused' comment)",
+		"6:28: warning: Parameter 'b' is never used (hint: remove it or add a '// jack:allow unused' comment)",
+		"7:14: warning: Local variable 'j' is assigned but never read (hint: remove it or add a '// jack:allow unused' comment)",
+	}
+
+	tkzr := tokenizer.New(input)
+	p := parser.New(tkzr)
+	class, diags := p.ParseClass()
+	if len(diags) != 0 {
+		t.Fatalf("ParseClass() unexpected diagnostics: %v", diags)
+	}
+
+	diags = Analyze(class)
+
+	if len(diags) != len(expected) {
+		t.Fatalf("Analyze() expected %d diagnostics, received: %v", len(expected), diags)
+	}
+	for i, d := range diags {
+		if d.String() != expected[i] {
+			t.Fatalf("Analyze() expected: %s, received: %s", expected[i], d.String())
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package xmlWriter
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/tivt2/jack-compiler/parseTree"
+	"github.com/tivt2/jack-compiler/token"
+)
+
+var escaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+)
+
+type XMLWriter struct {
+	file  *os.File
+	Out   bytes.Buffer
+	depth int
+}
+
+func New(path string) *XMLWriter {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Error while trying to create file: %s", path)
+	}
+
+	return &XMLWriter{
+		file: file,
+	}
+}
+
+func (w *XMLWriter) WriteTokens(tokens []token.Token) {
+	w.Out.WriteString("<tokens>\n")
+	for _, tk := range tokens {
+		if tk.Type == token.EOF {
+			continue
+		}
+		w.writeTerminal(string(tk.Category()), tk.Literal)
+	}
+	w.Out.WriteString("</tokens>\n")
+}
+
+func (w *XMLWriter) WriteClass(c *parseTree.Class) {
+	w.open("class")
+	w.writeToken(c.Token)
+	w.writeToken(c.Ident.Token)
+	w.writeSymbol("{")
+	cvds := c.ClassVars()
+	for i, cvd := range cvds {
+		if !cvd.Continued {
+			w.open("classVarDec")
+			w.writeToken(cvd.Kind)
+			w.writeToken(cvd.DecType)
+		} else {
+			w.writeSymbol(",")
+		}
+		w.writeToken(cvd.Ident.Token)
+		if i+1 == len(cvds) || !cvds[i+1].Continued {
+			w.writeSymbol(";")
+			w.close("classVarDec")
+		}
+	}
+	for _, sd := range c.Subroutines() {
+		w.writeSubroutineDec(sd)
+	}
+	w.writeSymbol("}")
+	w.close("class")
+}
+
+func (w *XMLWriter) writeSubroutineDec(sd *parseTree.SubroutineDec) {
+	w.open("subroutineDec")
+	w.writeToken(sd.Kind)
+	w.writeToken(sd.DecType)
+	w.writeToken(sd.Ident.Token)
+	w.writeSymbol("(")
+	w.open("parameterList")
+	for i, param := range sd.Params {
+		if i != 0 {
+			w.writeSymbol(",")
+		}
+		w.writeToken(param.DecType)
+		w.writeToken(param.Ident.Token)
+	}
+	w.close("parameterList")
+	w.writeSymbol(")")
+
+	w.open("subroutineBody")
+	w.writeSymbol("{")
+	vds := sd.SubroutineBody.Vars()
+	for i, vd := range vds {
+		if !vd.Continued {
+			w.open("varDec")
+			w.writeToken(vd.Kind)
+			w.writeToken(vd.DecType)
+		} else {
+			w.writeSymbol(",")
+		}
+		w.writeToken(vd.Ident.Token)
+		if i+1 == len(vds) || !vds[i+1].Continued {
+			w.writeSymbol(";")
+			w.close("varDec")
+		}
+	}
+	w.writeStatements(sd.SubroutineBody.Statements)
+	w.writeSymbol("}")
+	w.close("subroutineBody")
+	w.close("subroutineDec")
+}
+
+func (w *XMLWriter) writeStatements(stmts []parseTree.Statement) {
+	w.open("statements")
+	for _, stmt := range stmts {
+		w.writeStatement(stmt)
+	}
+	w.close("statements")
+}
+
+func (w *XMLWriter) writeStatement(stmt parseTree.Statement) {
+	switch stmt := stmt.(type) {
+	case *parseTree.LetStatement:
+		w.open("letStatement")
+		w.writeToken(stmt.Token)
+		w.writeToken(stmt.Ident.Token)
+		if stmt.Ident.Indexer != nil {
+			w.writeSymbol("[")
+			w.writeExpression(stmt.Ident.Indexer)
+			w.writeSymbol("]")
+		}
+		w.writeSymbol("=")
+		w.writeExpression(stmt.Expression)
+		w.writeSymbol(";")
+		w.close("letStatement")
+	case *parseTree.IfStatement:
+		w.open("ifStatement")
+		w.writeToken(stmt.Token)
+		w.writeSymbol("(")
+		w.writeExpression(stmt.Expression)
+		w.writeSymbol(")")
+		w.writeSymbol("{")
+		w.writeStatements(stmt.IfStmts)
+		w.writeSymbol("}")
+		if stmt.ElseToken.Type == token.ELSE {
+			w.writeToken(stmt.ElseToken)
+			w.writeSymbol("{")
+			w.writeStatements(stmt.Else)
+			w.writeSymbol("}")
+		}
+		w.close("ifStatement")
+	case *parseTree.WhileStatement:
+		w.open("whileStatement")
+		w.writeToken(stmt.Token)
+		w.writeSymbol("(")
+		w.writeExpression(stmt.Expression)
+		w.writeSymbol(")")
+		w.writeSymbol("{")
+		w.writeStatements(stmt.Stmts)
+		w.writeSymbol("}")
+		w.close("whileStatement")
+	case *parseTree.DoStatement:
+		w.open("doStatement")
+		w.writeToken(stmt.Token)
+		if call, ok := stmt.Expression.(*parseTree.SubroutineCall); ok {
+			w.writeSubroutineCall(call)
+		} else {
+			w.writeExpression(stmt.Expression)
+		}
+		w.writeSymbol(";")
+		w.close("doStatement")
+	case *parseTree.ReturnStatement:
+		w.open("returnStatement")
+		w.writeToken(stmt.Token)
+		if stmt.Expression != nil {
+			w.writeExpression(stmt.Expression)
+		}
+		w.writeSymbol(";")
+		w.close("returnStatement")
+	}
+}
+
+func (w *XMLWriter) writeExpression(exp parseTree.Expression) {
+	w.open("expression")
+	w.writeInfixChain(exp)
+	w.close("expression")
+}
+
+func (w *XMLWriter) writeInfixChain(exp parseTree.Expression) {
+	if infix, ok := exp.(*parseTree.Infix); ok {
+		w.writeInfixChain(infix.Left)
+		w.writeSymbol(infix.Operator.Literal)
+		w.writeTerm(infix.Right)
+		return
+	}
+	w.writeTerm(exp)
+}
+
+func (w *XMLWriter) writeTerm(exp parseTree.Expression) {
+	w.open("term")
+	switch exp := exp.(type) {
+	case *parseTree.IntegerConstant:
+		w.writeTerminal("integerConstant", exp.Token.Literal)
+	case *parseTree.StringConstant:
+		w.writeTerminal("stringConstant", exp.Value)
+	case *parseTree.KeywordConstant:
+		w.writeTerminal("keyword", exp.Value)
+	case *parseTree.Identifier:
+		w.writeToken(exp.Token)
+		if exp.Indexer != nil {
+			w.writeSymbol("[")
+			w.writeExpression(exp.Indexer)
+			w.writeSymbol("]")
+		}
+	case *parseTree.SubroutineCall:
+		w.writeSubroutineCall(exp)
+	case *parseTree.Prefix:
+		w.writeSymbol(exp.Operator.Literal)
+		w.writeTerm(exp.Expression)
+	case *parseTree.Group:
+		w.writeSymbol("(")
+		w.writeExpression(exp.Expression)
+		w.writeSymbol(")")
+	case *parseTree.Infix:
+		w.writeSymbol("(")
+		w.writeExpression(exp)
+		w.writeSymbol(")")
+	}
+	w.close("term")
+}
+
+func (w *XMLWriter) writeSubroutineCall(call *parseTree.SubroutineCall) {
+	if call.Ident != nil {
+		w.writeToken(call.Ident.Token)
+		w.writeSymbol(".")
+	}
+	w.writeToken(call.Subroutine.Token)
+	w.writeSymbol("(")
+	w.open("expressionList")
+	for i, exp := range call.ExpList {
+		if i != 0 {
+			w.writeSymbol(",")
+		}
+		w.writeExpression(exp)
+	}
+	w.close("expressionList")
+	w.writeSymbol(")")
+}
+
+func (w *XMLWriter) open(tag string) {
+	w.indent()
+	w.Out.WriteString("<" + tag + ">\n")
+	w.depth++
+}
+
+func (w *XMLWriter) close(tag string) {
+	w.depth--
+	w.indent()
+	w.Out.WriteString("</" + tag + ">\n")
+}
+
+func (w *XMLWriter) indent() {
+	w.Out.WriteString(strings.Repeat("  ", w.depth))
+}
+
+func (w *XMLWriter) writeToken(tk token.Token) {
+	w.writeTerminal(string(tk.Category()), tk.Literal)
+}
+
+func (w *XMLWriter) writeSymbol(symbol string) {
+	w.writeTerminal("symbol", symbol)
+}
+
+func (w *XMLWriter) writeTerminal(tag string, text string) {
+	w.indent()
+	w.Out.WriteString(fmt.Sprintf("<%s> %s </%s>\n", tag, escaper.Replace(text), tag))
+}
+
+func (w *XMLWriter) Close() {
+	w.file.WriteString(w.Out.String())
+	w.file.Close()
+}